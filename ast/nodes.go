@@ -13,6 +13,7 @@ type Expression interface {
 
 type Identifier struct {
 	Name string
+	Type *TypeAnnotation // optional, only set for declarations: `let x: number` or `func(x: number)`
 	Loc  *lexer.Location
 }
 
@@ -22,6 +23,33 @@ func (i Identifier) Location() *lexer.Location {
 
 func (i Identifier) String() string { return i.Name }
 
+// Declaration returns the identifier along with its type annotation (if any)
+func (i Identifier) Declaration() string {
+	if i.Type == nil {
+		return i.Name
+	}
+	return i.Name + ": " + i.Type.String()
+}
+
+// TypeAnnotation is an optional static type, e.g. `number`, `[string]` or `func`.
+// Annotations are ignored by both engines and only used by the typecheck pass.
+type TypeAnnotation struct {
+	Name string          // type name, e.g. "number"; empty for arrays
+	Elem *TypeAnnotation // array item type: [Elem]
+	Loc  *lexer.Location
+}
+
+func (t TypeAnnotation) Location() *lexer.Location {
+	return t.Loc
+}
+
+func (t TypeAnnotation) String() string {
+	if t.Elem != nil {
+		return "[" + t.Elem.String() + "]"
+	}
+	return t.Name
+}
+
 type String struct {
 	Value string
 	Loc   *lexer.Location
@@ -36,6 +64,7 @@ func (s String) String() string { return "\"" + s.Value + "\"" }
 type LetExpression struct {
 	Identifiers    []Identifier
 	Initialization Expression
+	Const          bool // declared with `const`, i.e. cannot be reassigned
 	Loc            *lexer.Location
 }
 
@@ -46,9 +75,13 @@ func (le LetExpression) Location() *lexer.Location {
 func (le LetExpression) String() string {
 	ids := []string{}
 	for _, id := range le.Identifiers {
-		ids = append(ids, id.String())
+		ids = append(ids, id.Declaration())
+	}
+	keyword := "let"
+	if le.Const {
+		keyword = "const"
 	}
-	return fmt.Sprintf("let %s = %s", strings.Join(ids, ", "), le.Initialization.String())
+	return fmt.Sprintf("%s %s = %s", keyword, strings.Join(ids, ", "), le.Initialization.String())
 }
 
 type CallExpression struct {
@@ -201,6 +234,19 @@ func (m ModExpression) String() string {
 	return fmt.Sprintf("(%s %% %s)", m.Left.String(), m.Right.String())
 }
 
+type PowExpression struct {
+	Left  Expression
+	Right Expression
+}
+
+func (p PowExpression) Location() *lexer.Location {
+	return p.Left.Location()
+}
+
+func (p PowExpression) String() string {
+	return fmt.Sprintf("(%s ** %s)", p.Left.String(), p.Right.String())
+}
+
 type LogicalAndExpression struct {
 	Left  Expression
 	Right Expression
@@ -347,10 +393,25 @@ func (e EqTestExpression) String() string {
 	return fmt.Sprintf("(%s == %s)", e.Left.String(), e.Right.String())
 }
 
+// InExpression tests whether Left is an item of an array or a tuple, a key of a map or a substring of a string
+type InExpression struct {
+	Left  Expression
+	Right Expression
+}
+
+func (i InExpression) Location() *lexer.Location {
+	return i.Left.Location()
+}
+
+func (i InExpression) String() string {
+	return fmt.Sprintf("(%s in %s)", i.Left.String(), i.Right.String())
+}
+
 type FuncExpression struct {
-	Arguments []Identifier
-	Body      Expression
-	Loc       *lexer.Location
+	Arguments  []Identifier
+	ReturnType *TypeAnnotation
+	Body       Expression
+	Loc        *lexer.Location
 }
 
 func (f FuncExpression) Location() *lexer.Location {
@@ -360,10 +421,14 @@ func (f FuncExpression) Location() *lexer.Location {
 func (f FuncExpression) String() string {
 	argumentsStrings := []string{}
 	for _, arg := range f.Arguments {
-		argumentsStrings = append(argumentsStrings, arg.String())
+		argumentsStrings = append(argumentsStrings, arg.Declaration())
 	}
 
-	return fmt.Sprintf("func(%s) %s", strings.Join(argumentsStrings, ", "), f.Body.String())
+	var returnType string
+	if f.ReturnType != nil {
+		returnType = ": " + f.ReturnType.String()
+	}
+	return fmt.Sprintf("func(%s)%s %s", strings.Join(argumentsStrings, ", "), returnType, f.Body.String())
 }
 
 type ReturnExpression struct {
@@ -379,6 +444,20 @@ func (r ReturnExpression) String() string {
 	return fmt.Sprintf("return %s", r.Expr.String())
 }
 
+// DeferExpression schedules Expr to be evaluated when the enclosing function returns
+type DeferExpression struct {
+	Expr Expression
+	Loc  *lexer.Location
+}
+
+func (d DeferExpression) Location() *lexer.Location {
+	return d.Loc
+}
+
+func (d DeferExpression) String() string {
+	return fmt.Sprintf("defer %s", d.Expr.String())
+}
+
 type ContinueExpression struct {
 	loc *lexer.Location
 }
@@ -525,12 +604,15 @@ func (w WhileExpression) String() string {
 	return fmt.Sprintf("while %s %s", w.Condition.String(), w.Body.String())
 }
 
+// ForExpression with several generators, e.g. `for x in a, y in b => [x, y]`,
+// is represented as nested ForExpressions: Body of the outer one is the inner ForExpression.
 type ForExpression struct {
-	Index *Identifier
-	Value Identifier
-	Range Expression
-	Body  Expression
-	Loc   *lexer.Location
+	Index  *Identifier
+	Value  Identifier
+	Range  Expression
+	Filter Expression // optional, iterations for which it is false are skipped
+	Body   Expression
+	Loc    *lexer.Location
 }
 
 func (f ForExpression) Location() *lexer.Location {
@@ -543,7 +625,26 @@ func (f ForExpression) String() string {
 		iterators = append(iterators, f.Index.String())
 	}
 	iterators = append(iterators, f.Value.String())
-	return fmt.Sprintf("for %s in %s %s", strings.Join(iterators, ", "), f.Range.String(), f.Body.String())
+	filter := ""
+	if f.Filter != nil {
+		filter = " if " + f.Filter.String()
+	}
+	if inner, ok := f.Body.(ForExpression); ok {
+		return fmt.Sprintf("for %s in %s%s, %s", strings.Join(iterators, ", "), f.Range.String(), filter, strings.TrimPrefix(inner.String(), "for "))
+	}
+	return fmt.Sprintf("for %s in %s%s %s", strings.Join(iterators, ", "), f.Range.String(), filter, f.Body.String())
+}
+
+// Arrow returns the arrow expression producing values of a loop comprehension,
+// or false if the loop has a regular block body
+func (f ForExpression) Arrow() (ArrowExpression, bool) {
+	switch body := f.Body.(type) {
+	case ArrowExpression:
+		return body, true
+	case ForExpression:
+		return body.Arrow()
+	}
+	return ArrowExpression{}, false
 }
 
 // todo: support hashes (or somehow combine them with structs?)
@@ -621,6 +722,7 @@ func (i Import) String() string {
 }
 
 type ArrowExpression struct {
+	Key  Expression // only set in map comprehensions, e.g. `for x in a => x: x*x`
 	Expr Expression
 	Loc  *lexer.Location
 }
@@ -630,5 +732,8 @@ func (a ArrowExpression) Location() *lexer.Location {
 }
 
 func (a ArrowExpression) String() string {
+	if a.Key != nil {
+		return fmt.Sprintf("=> %s: %s", a.Key.String(), a.Expr.String())
+	}
 	return fmt.Sprintf("=> %s", a.Expr.String())
 }
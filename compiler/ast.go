@@ -129,6 +129,13 @@ func (c *Compiler) compileModExpression(node ast.ModExpression) error {
 		c.emitInstruction(instruction.OpMod),
 	)
 }
+func (c *Compiler) compilePowExpression(node ast.PowExpression) error {
+	return iferr(
+		c.emitNode(node.Right),
+		c.emitNode(node.Left),
+		c.emitInstruction(instruction.OpPow),
+	)
+}
 func (c *Compiler) compileGtExpression(node ast.GtExpression) error {
 	return iferr(
 		c.emitNode(node.Right),
@@ -190,6 +197,13 @@ func (c *Compiler) compileEqTestExpression(node ast.EqTestExpression) error {
 		c.emitInstruction(instruction.OpEqTest),
 	)
 }
+func (c *Compiler) compileInExpression(node ast.InExpression) error {
+	return iferr(
+		c.emitNode(node.Right),
+		c.emitNode(node.Left),
+		c.emitInstruction(instruction.OpIn),
+	)
+}
 func (c *Compiler) compileExpressions(node []ast.Expression) error {
 	for _, expr := range node {
 		if err := c.emitNode(expr); err != nil {
@@ -311,6 +325,14 @@ func (c *Compiler) scopeSM(node ast.Expression) func() {
 	}
 }
 func (c *Compiler) compileWhileExpression(node ast.WhileExpression) error {
+	if arrow, ok := node.Body.(ast.ArrowExpression); ok {
+		return c.compileComprehension(arrow, ast.WhileExpression{
+			Condition: node.Condition,
+			Body:      ast.BlockExpression{Stmts: []ast.Statement{{Expr: loopCollector(arrow)}}},
+			Loc:       node.Loc,
+		})
+	}
+
 	whileCode, err := c.makeClosure(func() (*code, error) { // should this really be a closure?
 		return c.makecb(func() error {
 			defer c.scopeSM(node)()
@@ -324,47 +346,113 @@ func (c *Compiler) compileWhileExpression(node ast.WhileExpression) error {
 		c.emitInstruction(instruction.OpCall, 0),
 	)
 }
-func (c *Compiler) compileForExpression(node ast.ForExpression) error {
-	// convert the for into a while:
+
+// compileComprehension compiles a loop with an arrow body, which resolves to an array (or a map) of the values
+// produced by the arrow expression on every iteration. The loop must add these values to "!r" using loopCollector.
+func (c *Compiler) compileComprehension(arrow ast.ArrowExpression, loop ast.Expression) error {
 	/**
+	let __r = []; // or map{} for map comprehensions
+	<loop>;
+	__r; // resolve to this
+	*/
+	var result ast.Expression = ast.ArrayExpression{Items: nil}
+	if arrow.Key != nil {
+		result = ast.MapExpression{}
+	}
+	block := ast.BlockExpression{
+		Stmts: []ast.Statement{
+			{Expr: ast.LetExpression{ // let r = [];
+				Identifiers:    []ast.Identifier{{Name: "!r"}},
+				Initialization: result,
+			}},
+			{Expr: loop},
+		},
+	}
 
-	regular loop:
-	let __ii = iteritems(arr);
-	let __l = len(ii);
-	let __i = -1;
-	let __res = null; // resolve to this
-	while __i++ < __l {
-		let key = __ii.(__i).0;
-		let value = __ii.(__i).1;
+	c.pushSymbolsLinked()
+	blockCode, err := c.make(block)
+	sym := c.popSymbols()
+	if err != nil {
+		return err
+	}
 
-		// body
-	};
+	return iferr(
+		c.emit(blockCode),
+		c.emitInstruction(instruction.OpPop), // remove the "null"
+		c.emitPushSymbol(sym.getLocal("!r")),
+	)
+}
 
-	loop with an arrow expression:
-	let __ii = iteritems(arr);
-	let __l = len(ii);
-	let __i = -1;
-	let __r = []; // resolve to this
-	while __i++ < __l {
-		let key = __ii.(__i).0;
-		let value = __ii.(__i).1;
+// loopCollector returns an expression adding the value of the arrow expression to the loop result "!r"
+func loopCollector(arrow ast.ArrowExpression) ast.Expression {
+	if arrow.Key != nil {
+		return ast.FieldAssignExpression{ // r.(key) = value;
+			FieldAccess: ast.FieldAccessExpression{
+				Left:  ast.Identifier{Name: "!r"},
+				Right: arrow.Key,
+			},
+			Value: arrow.Expr,
+		}
+	}
+	return ast.CallExpression{ // append(r, value);
+		Callee:    ast.Identifier{Name: "append"},
+		Arguments: []ast.Expression{ast.Identifier{Name: "!r"}, arrow.Expr},
+	}
+}
 
-		__r = __r + [<body()>];
+// loopBody returns the body of the for loop, in which the filter, nested generators
+// and the arrow expression are turned into regular statements
+func loopBody(node ast.ForExpression) ast.Expression {
+	body := node.Body
+	switch b := node.Body.(type) {
+	case ast.ArrowExpression:
+		body = loopCollector(b)
+	case ast.ForExpression:
+		b.Body = loopBody(b)
+		b.Filter = nil
+		body = b
+	}
+	if node.Filter != nil {
+		body = ast.IfExpression{
+			Condition: node.Filter,
+			Then:      ast.BlockExpression{Stmts: []ast.Statement{{Expr: body}}},
+			Loc:       node.Filter.Location(),
+		}
+	}
+	return body
+}
+func (c *Compiler) compileForExpression(node ast.ForExpression) error {
+	if arrow, ok := node.Arrow(); ok {
+		return c.compileComprehension(arrow, ast.ForExpression{
+			Index: node.Index,
+			Value: node.Value,
+			Range: node.Range,
+			Body:  ast.BlockExpression{Stmts: []ast.Statement{{Expr: loopBody(node)}}},
+			Loc:   node.Loc,
+		})
+	}
+
+	// convert the for into a while:
+	/**
+	let __it = !iterate(arr);
+	let __item = null;
+	while (__item = !next(__it)) != null {
+		let key = __item.0;
+		let value = __item.1;
+
+		// body
 	};
 
+	loops with an arrow expression are wrapped by compileComprehension,
+	their body is converted to a regular one by loopBody
 	*/
 
-	_, isArrow := node.Body.(ast.ArrowExpression)
-
 	var whileBody []ast.Statement
 	if node.Index != nil {
 		whileBody = append(whileBody, ast.Statement{Expr: ast.LetExpression{
 			Identifiers: []ast.Identifier{*node.Index},
 			Initialization: ast.FieldAccessExpression{
-				Left: ast.FieldAccessExpression{
-					Left:  ast.Identifier{Name: "!ii"},
-					Right: ast.Identifier{Name: "!i"},
-				},
+				Left:  ast.Identifier{Name: "!item"},
 				Right: ast.NumberExpression{Value: 0},
 			},
 		}})
@@ -372,64 +460,38 @@ func (c *Compiler) compileForExpression(node ast.ForExpression) error {
 	whileBody = append(whileBody, ast.Statement{Expr: ast.LetExpression{
 		Identifiers: []ast.Identifier{node.Value},
 		Initialization: ast.FieldAccessExpression{
-			Left: ast.FieldAccessExpression{
-				Left:  ast.Identifier{Name: "!ii"},
-				Right: ast.Identifier{Name: "!i"},
-			},
+			Left:  ast.Identifier{Name: "!item"},
 			Right: ast.NumberExpression{Value: 1},
 		},
 	}})
-	if isArrow {
-		//whileBody = append(whileBody, ast.Statement{Expr: ast.AssignExpression{
-		//	Identifier: ast.Identifier{Name: "!r"},
-		//	Value: ast.PlusExpression{
-		//		Left:  ast.Identifier{Name: "!r"},
-		//		Right: ast.ArrayExpression{Items: []ast.Expression{node.Body.(ast.ArrowExpression).Expr}},
-		//	},
-		//}})
-		whileBody = append(whileBody, ast.Statement{Expr: ast.CallExpression{
-			Callee:    ast.Identifier{Name: "append"},
-			Arguments: []ast.Expression{ast.Identifier{Name: "!r"}, node.Body.(ast.ArrowExpression).Expr},
-		}})
-	} else {
-		whileBody = append(whileBody, ast.Statement{Expr: node.Body})
-	}
+	whileBody = append(whileBody, ast.Statement{Expr: loopBody(node)})
 
 	while := ast.BlockExpression{
 		Stmts: []ast.Statement{
-			{Expr: ast.LetExpression{ // let ii = iteritems(arr);
-				Identifiers: []ast.Identifier{{Name: "!ii"}}, // ! is added to the var name to guarantee that it does not collide with user-specified local variables
+			{Expr: ast.LetExpression{ // let it = !iterate(arr);
+				Identifiers: []ast.Identifier{{Name: "!it"}}, // ! is added to the var name to guarantee that it does not collide with user-specified local variables
 				Initialization: ast.CallExpression{
-					Callee:    ast.Identifier{Name: "iteritems"},
+					Callee:    ast.Identifier{Name: "!iterate"},
 					Arguments: []ast.Expression{node.Range},
 				},
 			}},
-			{Expr: ast.LetExpression{ // let l = len(ii);
-				Identifiers: []ast.Identifier{{Name: "!l"}},
-				Initialization: ast.CallExpression{
-					Callee:    ast.Identifier{Name: "len"},
-					Arguments: []ast.Expression{ast.Identifier{Name: "!ii"}},
-				},
-			}},
-			{Expr: ast.LetExpression{ // let i = -1;
-				Identifiers:    []ast.Identifier{{Name: "!i"}},
-				Initialization: ast.NumberExpression{Value: -1},
-			}},
-			{Expr: ast.LetExpression{ // let r = [];
-				Identifiers:    []ast.Identifier{{Name: "!r"}},
-				Initialization: ast.ArrayExpression{Items: nil},
+			{Expr: ast.LetExpression{ // let item = null;
+				Identifiers:    []ast.Identifier{{Name: "!item"}},
+				Initialization: ast.Identifier{Name: "null"},
 			}},
-			{Expr: ast.WhileExpression{ // while __i++ < __l {
+			{Expr: ast.WhileExpression{ // while (item = !next(it)) != null {
 				Loc: node.Location(),
-				Condition: ast.LtExpression{
-					Left: ast.AssignExpression{
-						Identifier: ast.Identifier{Name: "!i"},
-						Value: ast.PlusExpression{
-							Left:  ast.Identifier{Name: "!i"},
-							Right: ast.NumberExpression{Value: 1},
+				Condition: ast.NegationExpression{
+					Expr: ast.EqTestExpression{
+						Left: ast.AssignExpression{
+							Identifier: ast.Identifier{Name: "!item"},
+							Value: ast.CallExpression{
+								Callee:    ast.Identifier{Name: "!next"},
+								Arguments: []ast.Expression{ast.Identifier{Name: "!it"}},
+							},
 						},
+						Right: ast.Identifier{Name: "null"},
 					},
-					Right: ast.Identifier{Name: "!l"},
 				},
 				Body: ast.BlockExpression{
 					Stmts: whileBody,
@@ -440,20 +502,13 @@ func (c *Compiler) compileForExpression(node ast.ForExpression) error {
 
 	c.pushSymbolsLinked()
 	whileCode, err := c.make(while)
-	sym := c.popSymbols()
+	c.popSymbols()
 	if err != nil {
 		return err
 	}
 
 	c.annotate("for start")
-	err = c.emit(whileCode)
-	if isArrow {
-		err = iferr(err,
-			c.emitInstruction(instruction.OpPop), // remove the "null"
-			c.emitPushSymbol(sym.getLocal("!r")),
-		)
-	}
-	return err
+	return c.emit(whileCode)
 }
 func (c *Compiler) compileLetExpression(node ast.LetExpression) error {
 	syms := make([]*Symbol, len(node.Identifiers))
@@ -463,6 +518,7 @@ func (c *Compiler) compileLetExpression(node ast.LetExpression) error {
 		}
 
 		syms[i] = c.symbols.createLocal(id.Name)
+		syms[i].readonly = node.Const
 	}
 
 	var err error
@@ -507,6 +563,9 @@ func (c *Compiler) compileTupleAssignExpression(node ast.TupleAssignExpression)
 			if sym == nil {
 				return fmt.Errorf("identifier is not declared in this scope: " + expr.Name)
 			}
+			if sym.readonly {
+				return fmt.Errorf("cannot assign to a constant: %s", expr.Name)
+			}
 			err = iferr(err,
 				c.emitStoreSymbol(sym),
 				c.emitInstruction(instruction.OpPop),
@@ -530,6 +589,9 @@ func (c *Compiler) compileAssignExpression(node ast.AssignExpression) error {
 	if sym == nil {
 		return fmt.Errorf("cannot assign to unknown identifier: %s", node.Identifier.Name)
 	}
+	if sym.readonly {
+		return fmt.Errorf("cannot assign to a constant: %s", node.Identifier.Name)
+	}
 
 	return iferr(
 		c.emitNode(node.Value),
@@ -558,6 +620,21 @@ func (c *Compiler) compileReturnExpression(node ast.ReturnExpression) error {
 		c.emitInstruction(instruction.OpReturn, int(object.CodeReturnScopeFunc)),
 	)
 }
+func (c *Compiler) compileDeferExpression(node ast.DeferExpression) error {
+	if c.funcDepth == 0 {
+		return fmt.Errorf("defer outside of a function")
+	}
+	// the expression is wrapped into a closure without arguments, which the vm calls when leaving the function
+	dc, err := c.makeClosure(func() (*code, error) {
+		return c.make(node.Expr)
+	}, nil, object.CodeReturnScopeFunc)
+	return iferr(
+		err,
+		c.emit(dc),
+		c.emitInstruction(instruction.OpDefer),
+		c.emitPushNull(),
+	)
+}
 func (c *Compiler) compileBreakExpression(node ast.BreakExpression) error {
 	// todo: check if inside a loop
 	return iferr(
@@ -613,9 +690,7 @@ func (c *Compiler) compileExports(node ast.Exports) error {
 func (c *Compiler) compileImport(node ast.Import) error {
 	return iferr(
 		c.emitNode(node.Module),
-		c.emitInstruction(instruction.OpImport), // resolves to code
-		c.emitInstruction(instruction.OpClosure),
-		c.emitInstruction(instruction.OpCall, 0),
+		c.emitInstruction(instruction.OpImport), // resolves to a struct with the exported fields
 	)
 }
 func (c *Compiler) CompileModule(node ast.Module) (*Module, error) {
@@ -682,6 +757,8 @@ func (c *Compiler) CompileModule(node ast.Module) (*Module, error) {
 	return module, nil
 }
 func (c *Compiler) compileFuncExpression(node ast.FuncExpression) error {
+	c.funcDepth++
+	defer func() { c.funcDepth-- }()
 	fc, err := c.makeClosure(func() (*code, error) {
 		return c.makecb(func() error {
 			return iferr(
@@ -727,6 +804,8 @@ func (c *Compiler) emitNode(node ast.Expression) error {
 		return c.compileDivExpression(node)
 	case ast.ModExpression:
 		return c.compileModExpression(node)
+	case ast.PowExpression:
+		return c.compilePowExpression(node)
 	case ast.GtExpression:
 		return c.compileGtExpression(node)
 	case ast.LtExpression:
@@ -741,6 +820,8 @@ func (c *Compiler) emitNode(node ast.Expression) error {
 		return c.compileLogicalOrExpression(node)
 	case ast.EqTestExpression:
 		return c.compileEqTestExpression(node)
+	case ast.InExpression:
+		return c.compileInExpression(node)
 	case ast.Module:
 		panic("unexpected module compilation")
 	case ast.IfExpression:
@@ -763,6 +844,8 @@ func (c *Compiler) emitNode(node ast.Expression) error {
 		return c.compileBreakExpression(node)
 	case ast.ContinueExpression:
 		return c.compileContinueExpression(node)
+	case ast.DeferExpression:
+		return c.compileDeferExpression(node)
 	case ast.ArrowExpression:
 		return c.compileArrowExpression(node)
 	case ast.GroupExpression:
@@ -45,6 +45,7 @@ type Compiler struct {
 	scopes    []*scope
 	symbols   *symbols
 	debugData map[int]*DebugData
+	funcDepth int // number of enclosing function expressions
 }
 
 func NewCompilerWithStorage(objects *object.Storage) *Compiler {
@@ -356,3 +356,33 @@ func (Import) Op() Op {
 func (i Import) String() string {
 	return fmt.Sprintf("%s", i.Op().String())
 }
+
+type Defer struct {
+}
+
+func (Defer) Op() Op {
+	return OpDefer
+}
+func (d Defer) String() string {
+	return fmt.Sprintf("%s", d.Op().String())
+}
+
+type In struct {
+}
+
+func (In) Op() Op {
+	return OpIn
+}
+func (i In) String() string {
+	return fmt.Sprintf("%s", i.Op().String())
+}
+
+type Pow struct {
+}
+
+func (Pow) Op() Op {
+	return OpPow
+}
+func (p Pow) String() string {
+	return fmt.Sprintf("%s", p.Op().String())
+}
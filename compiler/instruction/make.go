@@ -8,7 +8,7 @@ import (
 
 func Size(op Op) int {
 	switch op {
-	case OpAdd, OpMult, OpGt, OpGte, OpLt, OpLte, OpClosure, OpSub, OpDiv, OpMod, OpEqTest, OpFieldAccess, OpFieldAssign, OpPop, OpLogicalOr, OpCopy, OpDup, OpImport:
+	case OpAdd, OpMult, OpGt, OpGte, OpLt, OpLte, OpClosure, OpSub, OpDiv, OpMod, OpEqTest, OpFieldAccess, OpFieldAssign, OpPop, OpLogicalOr, OpCopy, OpDup, OpImport, OpDefer, OpIn, OpPow:
 		return 1
 	case OpCall, OpReturn, OpStruct, OpMap, OpLabel, OpTuple, OpUntuple:
 		return 2
@@ -30,7 +30,7 @@ func Sizes(ops ...Op) int {
 func ReadFast(b []byte, p int, args []interface{}) (Op, int) {
 	op := Op(b[p])
 	switch op {
-	case OpAdd, OpMult, OpGt, OpGte, OpLt, OpLte, OpClosure, OpSub, OpDiv, OpMod, OpEqTest, OpFieldAccess, OpFieldAssign, OpPop, OpLogicalOr, OpCopy, OpDup, OpImport:
+	case OpAdd, OpMult, OpGt, OpGte, OpLt, OpLte, OpClosure, OpSub, OpDiv, OpMod, OpEqTest, OpFieldAccess, OpFieldAssign, OpPop, OpLogicalOr, OpCopy, OpDup, OpImport, OpDefer, OpIn, OpPow:
 		return op, 1
 	case OpCall, OpReturn, OpStruct, OpMap, OpLabel, OpTuple, OpUntuple:
 		args[0] = b[p+1]
@@ -68,6 +68,12 @@ func build(op Op, args args) (Instruction, error) {
 		return Dup{}, nil
 	case OpImport:
 		return Import{}, nil
+	case OpDefer:
+		return Defer{}, nil
+	case OpIn:
+		return In{}, nil
+	case OpPow:
+		return Pow{}, nil
 	case OpLogicalOr:
 		return LogicalOr{}, nil
 	case OpPushConstant:
@@ -209,7 +215,7 @@ func Bytes(i Instruction) []byte {
 		return nil
 	}
 	switch inst := i.(type) {
-	case Add, Gt, Lt, Gte, Lte, Mult, Closure, Sub, Div, Mod, EqTest, FieldAccess, FieldAssign, LogicalOr, Copy, Dup, Import:
+	case Add, Gt, Lt, Gte, Lte, Mult, Closure, Sub, Div, Mod, EqTest, FieldAccess, FieldAssign, LogicalOr, Copy, Dup, Import, Defer, In, Pow:
 		return bytes(inst.Op())
 	case Call:
 		return bytes(inst.Op(), inst.Args)
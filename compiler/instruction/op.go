@@ -41,6 +41,9 @@ const (
 	OpFieldAssign
 	OpImport
 	OpLabel
+	OpDefer
+	OpIn
+	OpPow
 )
 
 func (o Op) String() string {
@@ -115,6 +118,12 @@ func (o Op) String() string {
 		return "IMPORT"
 	case OpLabel:
 		return "LABEL"
+	case OpDefer:
+		return "DEFER"
+	case OpIn:
+		return "IN"
+	case OpPow:
+		return "POW"
 	default:
 		panic("cannot stringify unknown op: " + strconv.Itoa(int(o)))
 	}
@@ -22,9 +22,10 @@ func (s symbolScope) String() string {
 }
 
 type Symbol struct {
-	name  string
-	id    int
-	scope symbolScope
+	name     string
+	id       int
+	scope    symbolScope
+	readonly bool // declared with `const`
 }
 type symbols struct {
 	scopeId    int
@@ -113,9 +114,10 @@ func (s *symbols) importLocal(sym *Symbol) {
 }
 func (s *symbols) createForeign(key string, original *Symbol) *Symbol {
 	ret := &Symbol{
-		name:  key,
-		id:    len(s.linkedRoot.foreign),
-		scope: symbolScopeForeign,
+		name:     key,
+		id:       len(s.linkedRoot.foreign),
+		scope:    symbolScopeForeign,
+		readonly: original.readonly,
 	}
 	s.linkedRoot.foreign = append(s.linkedRoot.foreign, original)
 	s.set(key, ret)
@@ -10,17 +10,7 @@ func newBuiltinEnvironment() *object.Environment {
 	env := object.NewEnvironment()
 
 	for key, value := range funcs.BuiltinFunctions {
-		var args []ast.Identifier
-		for _, arg := range value.Arguments {
-			args = append(args, ast.Identifier{Name: arg})
-		}
-		env.Set(key, &object.Function{
-			Env: env,
-			Node: ast.FuncExpression{
-				Arguments: args,
-				Body:      ast.BuiltinFunction{Name: key},
-			},
-		})
+		env.Set(key, builtinFunction(env, key, value))
 	}
 
 	for key, value := range funcs.Builtins {
@@ -29,3 +19,17 @@ func newBuiltinEnvironment() *object.Environment {
 
 	return env
 }
+
+func builtinFunction(env *object.Environment, name string, builtin funcs.BuiltinFunction) *object.Function {
+	var args []ast.Identifier
+	for _, arg := range builtin.Arguments {
+		args = append(args, ast.Identifier{Name: arg})
+	}
+	return &object.Function{
+		Env: env,
+		Node: ast.FuncExpression{
+			Arguments: args,
+			Body:      ast.BuiltinFunction{Name: name},
+		},
+	}
+}
@@ -1,21 +1,71 @@
 package eval
 
 import (
+	"io"
+	"os"
 	"reflect"
 	"ryanlang/ast"
+	"ryanlang/funcs"
+	"ryanlang/lexer"
 	"ryanlang/object"
 )
 
 type Evaluator struct {
-	env *object.Environment
+	env    *object.Environment
+	defers *[]deferred // calls deferred in the enclosing function, nil outside of functions
+	host   *host
+	loc    *lexer.Location // location of the call of the function being evaluated
+}
+
+// host is shared by all evaluators running a program
+type host struct {
+	stdout io.Writer
+	stderr io.Writer
+	clock  funcs.Clock
+	policy funcs.Policy
+}
+
+func newHost() *host {
+	return &host{stdout: os.Stdout, stderr: os.Stderr, clock: funcs.SystemClock}
+}
+
+type deferred struct {
+	expr ast.Expression
+	env  *object.Environment
 }
 
 func New() *Evaluator {
-	return &Evaluator{env: newBuiltinEnvironment()}
+	return &Evaluator{env: newBuiltinEnvironment(), host: newHost()}
 }
 
 func NewWithEnv(env *object.Environment) *Evaluator {
-	return &Evaluator{env: env}
+	return &Evaluator{env: env, host: newHost()}
+}
+
+// SetOutput redirects the output of the program
+func (e *Evaluator) SetOutput(stdout io.Writer, stderr io.Writer) {
+	e.host.stdout = stdout
+	e.host.stderr = stderr
+}
+
+// SetClock replaces the clock of the program, e.g. with a funcs.FakeClock
+func (e *Evaluator) SetClock(clock funcs.Clock) {
+	e.host.clock = clock
+}
+
+// SetPolicy restricts what the program can do to the host
+func (e *Evaluator) SetPolicy(policy funcs.Policy) {
+	e.host.policy = policy
+}
+
+// derive creates an evaluator for a nested scope within the same function
+func (e *Evaluator) derive() *Evaluator {
+	return &Evaluator{env: e.env.Derive(), defers: e.defers, host: e.host, loc: e.loc}
+}
+
+// withEnv creates an evaluator of the same program for a separate scope
+func (e *Evaluator) withEnv(env *object.Environment) *Evaluator {
+	return &Evaluator{env: env, host: e.host}
 }
 
 func (e *Evaluator) Eval(expr ast.Expression) object.Object {
@@ -28,6 +78,8 @@ func (e *Evaluator) Eval(expr ast.Expression) object.Object {
 		return e.evalModExpression(expr.(ast.ModExpression))
 	case ast.MultExpression:
 		return e.evalMultExpression(expr.(ast.MultExpression))
+	case ast.PowExpression:
+		return e.evalPowExpression(expr.(ast.PowExpression))
 	case ast.DivExpression:
 		return e.evalDivExpression(expr.(ast.DivExpression))
 	case ast.LogicalAndExpression:
@@ -52,6 +104,8 @@ func (e *Evaluator) Eval(expr ast.Expression) object.Object {
 		return e.evalCallExpression(expr.(ast.CallExpression))
 	case ast.ReturnExpression:
 		return e.evalReturnExpression(expr.(ast.ReturnExpression))
+	case ast.DeferExpression:
+		return e.evalDeferExpression(expr.(ast.DeferExpression))
 	case ast.ContinueExpression:
 		return e.evalContinueExpression(expr.(ast.ContinueExpression))
 	case ast.BreakExpression:
@@ -92,6 +146,8 @@ func (e *Evaluator) Eval(expr ast.Expression) object.Object {
 		return e.evalNegationExpression(expr.(ast.NegationExpression))
 	case ast.EqTestExpression:
 		return e.evalEqTestExpression(expr.(ast.EqTestExpression))
+	case ast.InExpression:
+		return e.evalInExpression(expr.(ast.InExpression))
 	case ast.PrefixMinusExpression:
 		return e.evalPrefixMinusExpression(expr.(ast.PrefixMinusExpression))
 	case ast.Module:
@@ -2,6 +2,7 @@ package eval
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"reflect"
 	"ryanlang/ast"
@@ -21,6 +22,9 @@ func (e *Evaluator) evalMinusExpression(expr ast.MinusExpression) object.Object
 func (e *Evaluator) evalModExpression(expr ast.ModExpression) object.Object {
 	return funcs.Mod(e.expectEvalToAnyType(expr.Left), e.expectEvalToAnyType(expr.Right))
 }
+func (e *Evaluator) evalPowExpression(expr ast.PowExpression) object.Object {
+	return funcs.Pow(e.expectEvalToAnyType(expr.Left), e.expectEvalToAnyType(expr.Right))
+}
 func (e *Evaluator) evalPrefixMinusExpression(expr ast.PrefixMinusExpression) object.Object {
 	var right object.Object
 
@@ -91,7 +95,11 @@ func (e *Evaluator) evalLetExpression(expr ast.LetExpression) object.Object {
 			return &object.Error{Msg: "identifier already declared: " + id.Name, Loc: id.Location()}
 		}
 
-		e.env.Set(id.Name, inits[index])
+		if expr.Const {
+			e.env.SetConst(id.Name, inits[index])
+		} else {
+			e.env.Set(id.Name, inits[index])
+		}
 	}
 
 	return ret
@@ -117,6 +125,9 @@ func (e *Evaluator) assignExpression(id ast.Identifier, value object.Object) obj
 		}
 	}
 	// todo: check if it's a builtin symbol, and probably disallow assigning to builtin symbols?
+	if e.env.IsConst(id.Name) {
+		return &object.Error{Msg: "cannot assign to a constant: " + id.Name, Loc: id.Loc}
+	}
 	if value.Type() != currentValue.Type() {
 		return &object.Error{Msg: "identifier already holds value of type: " + currentValue.Type().String(), Loc: id.Loc}
 	}
@@ -146,7 +157,7 @@ func (e *Evaluator) evalFuncExpression(expr ast.FuncExpression) object.Object {
 }
 func (e *Evaluator) evalBlockExpression(expr ast.BlockExpression) object.Object {
 	var ret object.Object
-	derivedEvaluator := NewWithEnv(e.env.Derive())
+	derivedEvaluator := e.derive()
 	for _, stmt := range expr.Stmts {
 		if ret = derivedEvaluator.expectEvalToAnyType(stmt); object.IsError(ret) {
 			return ret
@@ -165,7 +176,7 @@ func (e *Evaluator) evalBlockExpression(expr ast.BlockExpression) object.Object
 }
 func (e *Evaluator) evalModule(expr ast.Module) object.Object {
 	var exports *object.Exports
-	derivedEvaluator := NewWithEnv(e.env.Derive())
+	derivedEvaluator := e.withEnv(e.env.Derive())
 	for _, stmt := range expr.Block.Stmts {
 		var ret object.Object
 		if ret = derivedEvaluator.expectEvalToAnyType(stmt.Expr); object.IsError(ret) {
@@ -206,6 +217,15 @@ func (e *Evaluator) evalImport(expr ast.Import) object.Object {
 		return fn
 	}
 
+	if names, ok := funcs.NativeModuleFunctions(fn.(*object.String).Value); ok {
+		env := newBuiltinEnvironment()
+		module := &object.Module{Name: fn.(*object.String).Value, Exports: map[string]object.Object{}}
+		for _, name := range names {
+			module.Exports[name] = builtinFunction(env, module.Name+"."+name, funcs.NativeModules[module.Name][name])
+		}
+		return module
+	}
+
 	f, err := os.Open(fn.(*object.String).Value)
 	if err != nil {
 		return &object.Error{Msg: err.Error()}
@@ -214,7 +234,9 @@ func (e *Evaluator) evalImport(expr ast.Import) object.Object {
 	p := parser.New(l)
 
 	mod := p.ReadModule(fn.(*object.String).Value)
-	return New().Eval(mod)
+	imported := New()
+	imported.host = e.host
+	return imported.Eval(mod)
 }
 func (e *Evaluator) evalGroupExpression(expr ast.GroupExpression) object.Object {
 	return e.expectEvalToAnyType(expr.Expr)
@@ -228,23 +250,95 @@ func (e *Evaluator) evalCallExpression(expr ast.CallExpression) object.Object {
 		return callee
 	}
 
-	funcArguments := callee.(*object.Function).Node.Arguments
-	if len(funcArguments) != len(expr.Arguments) {
-		return &object.Error{Msg: fmt.Sprintf("expected %d arguments, got %d", len(funcArguments), len(expr.Arguments)), Loc: expr.Callee.Location()}
+	if err := checkArity(callee.(*object.Function), len(expr.Arguments)); err != nil {
+		err.Loc = expr.Callee.Location()
+		return err
 	}
 
-	derivedEvaluator := NewWithEnv(callee.(*object.Function).Env.Derive())
+	args := make([]object.Object, len(expr.Arguments))
 	for i, argExpr := range expr.Arguments {
-		var argValue object.Object
-		if argValue = e.expectEvalToAnyType(argExpr); object.IsError(argValue) {
-			return argValue
+		if args[i] = e.expectEvalToAnyType(argExpr); object.IsError(args[i]) {
+			return args[i]
 		}
+	}
+
+	return e.callFunction(callee.(*object.Function), args, expr.Location())
+}
 
-		derivedEvaluator.env.Set(funcArguments[i].Name, argValue)
+// Call implements funcs.Context, it lets built-in functions call functions
+func (e *Evaluator) Call(fn object.Object, args ...object.Object) object.Object {
+	f, ok := fn.(*object.Function)
+	if !ok {
+		return &object.Error{Msg: "function expected, got: " + fn.Type().String()}
+	}
+	if err := checkArity(f, len(args)); err != nil {
+		return err
+	}
+	return e.callFunction(f, args, e.loc)
+}
+
+// Stdout implements funcs.Context
+func (e *Evaluator) Stdout() io.Writer {
+	return e.host.stdout
+}
+
+// Stderr implements funcs.Context
+func (e *Evaluator) Stderr() io.Writer {
+	return e.host.stderr
+}
+
+// Clock implements funcs.Context
+func (e *Evaluator) Clock() funcs.Clock {
+	return e.host.clock
+}
+
+// Policy implements funcs.Context
+func (e *Evaluator) Policy() funcs.Policy {
+	return e.host.policy
+}
+
+// Location implements funcs.Context, it's the location of the call of the built-in function being evaluated
+func (e *Evaluator) Location() *lexer.Location {
+	return e.loc
+}
+
+// checkArity reports an error if the function cannot be called with n arguments
+func checkArity(f *object.Function, n int) *object.Error {
+	if body, ok := f.Node.Body.(ast.BuiltinFunction); ok {
+		if builtin, ok := funcs.LookupBuiltin(body.Name); ok && !builtin.Accepts(n) {
+			return &object.Error{Msg: fmt.Sprintf("expected %s arguments, got %d", builtin.Arity(), n)}
+		}
+		return nil
+	}
+	if len(f.Node.Arguments) != n {
+		return &object.Error{Msg: fmt.Sprintf("expected %d arguments, got %d", len(f.Node.Arguments), n)}
+	}
+	return nil
+}
+
+// callFunction calls the function with the arguments, loc is the location of the call
+func (e *Evaluator) callFunction(f *object.Function, args []object.Object, loc *lexer.Location) object.Object {
+	if f.Memo != nil {
+		return e.callMemoized(f, args, loc)
+	}
+	if body, ok := f.Node.Body.(ast.BuiltinFunction); ok {
+		if builtin, ok := funcs.LookupBuiltin(body.Name); ok {
+			args = builtin.Pack(args)
+		}
+	}
+	derivedEvaluator := e.withEnv(f.Env.Derive())
+	derivedEvaluator.loc = loc
+	derivedEvaluator.defers = &[]deferred{}
+	for i, arg := range f.Node.Arguments {
+		derivedEvaluator.env.Set(arg.Name, args[i])
 	}
 
 	var ret object.Object
-	if ret = derivedEvaluator.expectEvalToAnyType(callee.(*object.Function).Node.Body); object.IsError(ret) {
+	ret = derivedEvaluator.expectEvalToAnyType(f.Node.Body)
+	if deferErr := derivedEvaluator.runDeferred(); deferErr != nil && !object.IsError(ret) {
+		return deferErr
+	}
+	if object.IsError(ret) {
 		return ret
 	}
 
@@ -259,13 +353,55 @@ func (e *Evaluator) evalCallExpression(expr ast.CallExpression) object.Object {
 
 	return ret.(*object.ReturnObject).Obj
 }
+
+// callMemoized returns the cached result of the function, or calls it and caches the result unless it fails
+func (e *Evaluator) callMemoized(f *object.Function, args []object.Object, loc *lexer.Location) object.Object {
+	key, err := f.Memo.Key(args)
+	if err != nil {
+		return &object.Error{Msg: err.Error(), Loc: loc}
+	}
+	if ret, ok := f.Memo.Get(key); ok {
+		return ret
+	}
+	plain := *f
+	plain.Memo = nil
+	ret := e.callFunction(&plain, args, loc)
+	if !object.IsError(ret) {
+		f.Memo.Put(key, ret)
+	}
+	return ret
+}
 func (e *Evaluator) evalReturnExpression(expr ast.ReturnExpression) object.Object {
 	var ret object.Object
 	if ret = e.expectEvalToAnyType(expr.Expr); object.IsError(ret) {
 		return ret
 	}
+	if ret.Type() == object.RETURNOBJECT { // e.g. `func => for x in a => if x => return x`
+		return ret
+	}
 	return &object.ReturnObject{Obj: ret}
 }
+func (e *Evaluator) evalDeferExpression(expr ast.DeferExpression) object.Object {
+	if e.defers == nil {
+		return &object.Error{Msg: "defer outside of a function", Loc: expr.Location()}
+	}
+	*e.defers = append(*e.defers, deferred{expr: expr.Expr, env: e.env})
+	return &object.Null{}
+}
+
+// runDeferred evaluates the expressions deferred in the current function, the most recently deferred first.
+// All deferred expressions are evaluated even if some of them fail, the first error is returned.
+func (e *Evaluator) runDeferred() object.Object {
+	var firstErr object.Object
+	for i := len(*e.defers) - 1; i >= 0; i-- {
+		d := (*e.defers)[i]
+		if ret := e.withEnv(d.env).expectEvalToAnyType(d.expr); object.IsError(ret) && firstErr == nil {
+			firstErr = ret
+		}
+	}
+	*e.defers = nil
+	return firstErr
+}
 func (e *Evaluator) evalContinueExpression(expr ast.ContinueExpression) object.Object {
 	return &object.ContinueObject{}
 }
@@ -275,7 +411,7 @@ func (e *Evaluator) evalBreakExpression(expr ast.BreakExpression) object.Object
 func (e *Evaluator) evalIfExpression(expr ast.IfExpression) object.Object {
 	var condition object.Object
 
-	derivedEvaluator := NewWithEnv(e.env.Derive())
+	derivedEvaluator := e.derive()
 	// condition should be eval'ed inside a derived env, because:
 	/**
 	let f = func=>10;
@@ -343,18 +479,19 @@ func (e *Evaluator) evalLteExpression(expr ast.LteExpression) object.Object {
 	return &object.Boolean{Value: left.(*object.Number).Value <= right.(*object.Number).Value}
 }
 func (e *Evaluator) evalBuiltinFunction(expr ast.BuiltinFunction) object.Object {
-	if _, ok := funcs.BuiltinFunctions[expr.Name]; !ok {
+	builtin, ok := funcs.LookupBuiltin(expr.Name)
+	if !ok {
 		panic("unknown built-in function: " + expr.Name)
 	}
 	args := map[string]object.Object{}
-	for _, argName := range funcs.BuiltinFunctions[expr.Name].Arguments {
+	for _, argName := range builtin.Arguments {
 		v, ok := e.env.Get(argName)
 		if !ok {
 			return &object.Error{Msg: fmt.Sprintf("`%s` parameter required", argName)}
 		}
 		args[argName] = v
 	}
-	return funcs.BuiltinFunctions[expr.Name].Body(args)
+	return builtin.Invoke(e, args)
 }
 func (e *Evaluator) evalStructExpression(expr ast.StructExpression) object.Object {
 	ret := &object.Struct{
@@ -445,14 +582,18 @@ func (e *Evaluator) evalTupleAssignExpression(expr ast.TupleAssignExpression) ob
 		return lval
 	}
 	for index, exp := range expr.Tuple.Exprs {
+		var ret object.Object
 		switch exp.(type) {
 		case ast.Identifier:
-			e.assignExpression(exp.(ast.Identifier), rval.(*object.Tuple).Values[index])
+			ret = e.assignExpression(exp.(ast.Identifier), rval.(*object.Tuple).Values[index])
 		case ast.FieldAccessExpression:
-			e.fieldAssignExpressionValue(exp.(ast.FieldAccessExpression), rval.(*object.Tuple).Values[index])
+			ret = e.fieldAssignExpressionValue(exp.(ast.FieldAccessExpression), rval.(*object.Tuple).Values[index])
 		default:
 			return &object.Error{Msg: "identifier or dot-access expected on the lval tuple, got: " + reflect.TypeOf(exp).String()}
 		}
+		if object.IsError(ret) {
+			return ret
+		}
 	}
 
 	return &object.Null{}
@@ -461,6 +602,9 @@ func (e *Evaluator) evalWhileExpression(expr ast.WhileExpression) object.Object
 	var condition object.Object
 	var ret object.Object = &object.StaticNull
 
+	arrow, isArrow := expr.Body.(ast.ArrowExpression)
+	result := newLoopResult(arrow)
+
 	for {
 		if condition = e.expectEvalToType(expr.Condition, object.BOOLEAN); object.IsError(condition) {
 			return condition
@@ -470,11 +614,16 @@ func (e *Evaluator) evalWhileExpression(expr ast.WhileExpression) object.Object
 			break
 		}
 
-		if ret = e.expectEvalToAnyType(expr.Body); object.IsError(ret) {
+		if isArrow {
+			ret = e.collect(arrow, result)
+		} else {
+			ret = e.expectEvalToAnyType(expr.Body)
+		}
+		if object.IsError(ret) {
 			return ret
 		}
 		if ret.Type() == object.RETURNOBJECT {
-			break
+			return ret
 		}
 		if ret.Type() == object.BREAK {
 			ret = &object.Null{}
@@ -484,13 +633,64 @@ func (e *Evaluator) evalWhileExpression(expr ast.WhileExpression) object.Object
 			ret = &object.Null{}
 			// nothing to do, just continue
 		}
-
-		// todo: support arrow expression?
 	}
 
+	if isArrow {
+		return result
+	}
 	return ret
 }
+
+// newLoopResult creates the object a loop with the given arrow body resolves to
+func newLoopResult(arrow ast.ArrowExpression) object.Object {
+	if arrow.Key != nil {
+		return &object.Map{Fields: map[string]object.MapItem{}}
+	}
+	return &object.Array{}
+}
+
+// collect evaluates the arrow body of a loop and adds its value to the loop result
+func (e *Evaluator) collect(arrow ast.ArrowExpression, result object.Object) object.Object {
+	var key, value object.Object
+	if arrow.Key != nil {
+		if key = e.expectEvalToAnyType(arrow.Key); object.IsError(key) || isControlFlow(key) {
+			return key
+		}
+	}
+	if value = e.expectEvalToAnyType(arrow.Expr); object.IsError(value) || isControlFlow(value) {
+		return value
+	}
+	if arrow.Key != nil {
+		if ret := funcs.FieldAssign(result, key, value); object.IsError(ret) {
+			return &object.Error{Msg: ret.(*object.Error).Msg, Loc: arrow.Location()}
+		}
+	} else {
+		result.(*object.Array).Items = append(result.(*object.Array).Items, value)
+	}
+	return &object.StaticNull
+}
+
+func isControlFlow(obj object.Object) bool {
+	return obj.Type() == object.RETURNOBJECT || obj.Type() == object.BREAK || obj.Type() == object.CONTINUE
+}
+
 func (e *Evaluator) evalForExpression(expr ast.ForExpression) object.Object {
+	arrow, isArrow := expr.Arrow()
+	result := newLoopResult(arrow)
+
+	ret := e.evalForLoop(expr, arrow, result)
+	if object.IsError(ret) || ret.Type() == object.RETURNOBJECT {
+		return ret
+	}
+	if isArrow {
+		return result
+	}
+	return ret
+}
+
+// evalForLoop runs a single generator of the for loop,
+// values produced by the arrow expression are added to the result
+func (e *Evaluator) evalForLoop(expr ast.ForExpression, arrow ast.ArrowExpression, result object.Object) object.Object {
 	var r object.Object
 	var ret object.Object = &object.StaticNull
 
@@ -498,43 +698,47 @@ func (e *Evaluator) evalForExpression(expr ast.ForExpression) object.Object {
 		return r
 	}
 
-	var rangeItems []struct {
-		index object.Object
-		value object.Object
+	it := funcs.Iterator(r)
+	if object.IsError(it) {
+		return it
 	}
-	if r.Type() == object.ARRAY {
-		for k, v := range r.(*object.Array).Items {
-			rangeItems = append(rangeItems, struct {
-				index object.Object
-				value object.Object
-			}{index: &object.Number{Value: k}, value: v})
+
+	derivedEvaluator := e.derive()
+	for {
+		index, value := it.(*object.Iterator).Next()
+		if value == nil {
+			break
 		}
-	} else if r.Type() == object.MAP {
-		for _, item := range r.(*object.Map).Fields {
-			rangeItems = append(rangeItems, struct {
-				index object.Object
-				value object.Object
-			}{index: item.Key, value: item.Value})
+		if object.IsError(value) {
+			return value
 		}
-	} else {
-		return &object.Error{Msg: "cannot iterate over type " + r.Type().String()}
-	}
-
-	_, returnArrowExpression := expr.Body.(ast.ArrowExpression)
-	arrowItems := &object.Array{}
-
-	derivedEvaluator := NewWithEnv(e.env.Derive())
-	for _, v := range rangeItems {
 		if expr.Index != nil {
-			derivedEvaluator.env.Set(expr.Index.Name, v.index)
+			derivedEvaluator.env.Set(expr.Index.Name, index)
+		}
+		derivedEvaluator.env.Set(expr.Value.Name, value)
+
+		if expr.Filter != nil {
+			var filter object.Object
+			if filter = derivedEvaluator.expectEvalToType(expr.Filter, object.BOOLEAN); object.IsError(filter) {
+				return filter
+			}
+			if !filter.(*object.Boolean).Value {
+				continue
+			}
 		}
-		derivedEvaluator.env.Set(expr.Value.Name, v.value)
 
-		if ret = derivedEvaluator.expectEvalToAnyType(expr.Body); object.IsError(ret) {
+		switch body := expr.Body.(type) {
+		case ast.ArrowExpression:
+			ret = derivedEvaluator.collect(arrow, result)
+		case ast.ForExpression:
+			ret = derivedEvaluator.evalForLoop(body, arrow, result)
+		default:
+			ret = derivedEvaluator.expectEvalToAnyType(expr.Body)
+		}
+		if object.IsError(ret) {
 			return ret
 		}
 		if ret.Type() == object.RETURNOBJECT {
-			returnArrowExpression = false
 			break
 		}
 		if ret.Type() == object.BREAK {
@@ -546,13 +750,6 @@ func (e *Evaluator) evalForExpression(expr ast.ForExpression) object.Object {
 			// nothing to do, just continue
 			continue
 		}
-		if returnArrowExpression {
-			arrowItems.Items = append(arrowItems.Items, ret)
-			ret = &object.Null{}
-		}
-	}
-	if returnArrowExpression {
-		ret = arrowItems
 	}
 
 	return ret
@@ -579,6 +776,9 @@ func (e *Evaluator) evalNegationExpression(expr ast.NegationExpression) object.O
 func (e *Evaluator) evalEqTestExpression(expr ast.EqTestExpression) object.Object {
 	return funcs.EqTest(e.expectEvalToAnyType(expr.Left), e.expectEvalToAnyType(expr.Right))
 }
+func (e *Evaluator) evalInExpression(expr ast.InExpression) object.Object {
+	return funcs.In(e.expectEvalToAnyType(expr.Left), e.expectEvalToAnyType(expr.Right))
+}
 func (e *Evaluator) evalArrowExpression(expr ast.ArrowExpression) object.Object {
 	return e.expectEvalToAnyType(expr.Expr)
 }
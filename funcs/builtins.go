@@ -7,6 +7,8 @@ import (
 	"ryanlang/object"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var Builtins = map[string]object.Object{
@@ -14,24 +16,102 @@ var Builtins = map[string]object.Object{
 	"false": &object.StaticFalse,
 	"null":  &object.StaticNull,
 }
-var BuiltinFunctions = map[string]struct {
-	Arguments []string
-	Body      func(args map[string]object.Object) object.Object
-}{
-	"println": {
-		Arguments: []string{"s"},
+
+// ScriptArgs are the command line arguments passed to the program, returned by args()
+var ScriptArgs []string
+
+// Exit terminates the process, it's a variable so that the host can clean up before exiting
+var Exit = os.Exit
+
+var BuiltinFunctions = map[string]BuiltinFunction{
+	"println":  printFunc(Context.Stdout, true),
+	"eprintln": printFunc(Context.Stderr, true),
+	"print":    printFunc(Context.Stdout, false),
+	"format":   formatBuiltin,
+	"args": {
+		Arguments: []string{},
 		Body: func(args map[string]object.Object) object.Object {
-			fmt.Println(args["s"].String())
-			return &object.ReturnObject{Obj: &object.StaticNull}
+			return &object.ReturnObject{Obj: stringsArray(ScriptArgs)}
 		},
 	},
-	"print": {
-		Arguments: []string{"s"},
+	"env": {
+		Arguments: []string{"name"},
 		Body: func(args map[string]object.Object) object.Object {
-			fmt.Print(args["s"].String())
+			name, err := stringArg(args, "name")
+			if err != nil {
+				return err
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return &object.ReturnObject{Obj: &object.StaticNull}
+			}
+			return &object.ReturnObject{Obj: &object.String{Value: value}}
+		},
+	},
+	"exit": {
+		Arguments: []string{"code"},
+		Body: func(args map[string]object.Object) object.Object {
+			code, err := numberArg(args, "code")
+			if err != nil {
+				return err
+			}
+			Exit(code)
 			return &object.ReturnObject{Obj: &object.StaticNull}
 		},
 	},
+	"exec":      execBuiltin,
+	"sort":      sortBuiltin,
+	"sortby":    sortByBuiltin,
+	"heap":      heapBuiltin,
+	"deque":     dequeBuiltin,
+	"push":      pushBuiltin,
+	"pop":       popBuiltin,
+	"peek":      peekBuiltin,
+	"pushfront": pushFrontBuiltin,
+	"popfront":  popFrontBuiltin,
+	"peekfront": peekFrontBuiltin,
+	"memo": {
+		Arguments: []string{"f", "limit"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			limit := 0
+			if args["limit"].Type() != object.NULL {
+				var err object.Object
+				if limit, err = sizeArg(args, "limit"); err != nil {
+					return err
+				}
+			}
+			// the copy shares the code and the environment of the function, but calls to it go through the cache
+			switch f := args["f"].(type) {
+			case *object.Function:
+				memoized := *f
+				memoized.Memo = object.NewMemo(limit)
+				return returnValue(&memoized)
+			case *object.Closure:
+				memoized := *f
+				memoized.Memo = object.NewMemo(limit)
+				return returnValue(&memoized)
+			}
+			return argError("f", "function", args["f"])
+		},
+	},
+	"cache_clear": {
+		Arguments: []string{"f"},
+		Body: func(args map[string]object.Object) object.Object {
+			var memo *object.Memo
+			switch f := args["f"].(type) {
+			case *object.Function:
+				memo = f.Memo
+			case *object.Closure:
+				memo = f.Memo
+			}
+			if memo == nil {
+				return argError("f", "memoized function", args["f"])
+			}
+			memo.Clear()
+			return returnValue(&object.StaticNull)
+		},
+	},
 	"debugger": {
 		Arguments: []string{},
 		Body: func(args map[string]object.Object) object.Object {
@@ -53,11 +133,19 @@ var BuiltinFunctions = map[string]struct {
 			var val int
 			switch v.Type() {
 			case object.STRING:
-				val = len(v.(*object.String).Value)
+				val = utf8.RuneCountInString(v.(*object.String).Value)
 			case object.ARRAY:
 				val = len(v.(*object.Array).Items)
 			case object.MAP:
 				val = len(v.(*object.Map).Fields)
+			case object.RANGE:
+				val = v.(*object.Range).Len()
+			case object.HEAP:
+				val = len(v.(*object.Heap).Items)
+			case object.DEQUE:
+				val = v.(*object.Deque).Len()
+			case object.GRID:
+				val = len(v.(*object.Grid).Cells)
 			default:
 				return &object.Error{Msg: "cannot calculate len() on type " + v.Type().String()}
 			}
@@ -90,6 +178,33 @@ var BuiltinFunctions = map[string]struct {
 			return &object.ReturnObject{Obj: &object.String{Value: s}}
 		},
 	},
+	"ord": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			c, err := stringArg(args, "c")
+			if err != nil {
+				return err
+			}
+			r, size := utf8.DecodeRuneInString(c)
+			if size == 0 || size != len(c) {
+				return &object.Error{Msg: "argument c: a single character expected, got: " + strconv.Quote(c)}
+			}
+			return returnValue(&object.Number{Value: int(r)})
+		},
+	},
+	"chr": {
+		Arguments: []string{"n"},
+		Body: func(args map[string]object.Object) object.Object {
+			n, err := numberArg(args, "n")
+			if err != nil {
+				return err
+			}
+			if n < 0 || n > unicode.MaxRune || !utf8.ValidRune(rune(n)) {
+				return &object.Error{Msg: fmt.Sprintf("argument n: not a valid code point: %d", n)}
+			}
+			return returnValue(&object.String{Value: string(rune(n))})
+		},
+	},
 	"strsplit": {
 		Arguments: []string{"str", "sep"},
 		Body: func(args map[string]object.Object) object.Object {
@@ -118,6 +233,7 @@ var BuiltinFunctions = map[string]struct {
 			if err != nil {
 				return &object.Error{Msg: err.Error()}
 			}
+			defer f.Close()
 			ret := []object.Object{}
 			scanner := bufio.NewScanner(f)
 			for scanner.Scan() {
@@ -148,8 +264,17 @@ var BuiltinFunctions = map[string]struct {
 			a := args["a"]
 			s := args["s"]
 			e := args["e"]
+			if a.Type() == object.STRING && s.Type() == object.NUMBER && e.Type() == object.NUMBER {
+				// strings are sliced by characters
+				runes := []rune(a.(*object.String).Value)
+				from, to := s.(*object.Number).Value, e.(*object.Number).Value
+				if from < 0 || from > to || to > len(runes) {
+					return &object.Error{Msg: fmt.Sprintf("slice bounds out of range: %d to %d of %d", from, to, len(runes))}
+				}
+				return &object.ReturnObject{Obj: &object.String{Value: string(runes[from:to])}}
+			}
 			if a.Type() != object.ARRAY || s.Type() != object.NUMBER || e.Type() != object.NUMBER {
-				return &object.Error{Msg: "array and two numbers are expected"}
+				return &object.Error{Msg: "array or string and two numbers are expected"}
 			}
 			return &object.ReturnObject{Obj: &object.Array{Items: append([]object.Object{}, a.(*object.Array).Items[s.(*object.Number).Value:e.(*object.Number).Value]...)}}
 		},
@@ -164,6 +289,9 @@ var BuiltinFunctions = map[string]struct {
 			}
 
 			ret := a.(*object.Array)
+			if ret.Frozen {
+				return &object.Error{Msg: "cannot append to a frozen array"}
+			}
 			ret.Items = append(ret.Items, i)
 			return &object.ReturnObject{Obj: args["a"]}
 		},
@@ -203,10 +331,30 @@ var BuiltinFunctions = map[string]struct {
 			if m.Type() != object.MAP || !object.IsHashable(k) {
 				return &object.Error{Msg: "a map and a hashable object expected as arguments"}
 			}
+			if m.(*object.Map).Frozen {
+				return &object.Error{Msg: "cannot delete from a frozen map"}
+			}
 			delete(m.(*object.Map).Fields, k.(object.Hashable).Hash())
 			return &object.ReturnObject{Obj: &object.StaticNull}
 		},
 	},
+	"freeze": {
+		Arguments: []string{"v"},
+		Body: func(args map[string]object.Object) object.Object {
+			v := args["v"]
+			switch v := v.(type) {
+			case *object.Array:
+				v.Frozen = true
+			case *object.Map:
+				v.Frozen = true
+			case *object.Struct:
+				v.Frozen = true
+			default:
+				return &object.Error{Msg: "array, map or struct expected, got: " + v.Type().String()}
+			}
+			return &object.ReturnObject{Obj: v}
+		},
+	},
 	"iteritems": {
 		Arguments: []string{"a"},
 		Body: func(args map[string]object.Object) object.Object {
@@ -222,10 +370,67 @@ var BuiltinFunctions = map[string]struct {
 				for _, v := range a.(*object.Map).Fields {
 					ret.(*object.Array).Items = append(ret.(*object.Array).Items, &object.Array{Items: []object.Object{v.Key, v.Value}})
 				}
+			case object.HEAP, object.DEQUE:
+				var values []object.Object
+				if a.Type() == object.HEAP {
+					values = a.(*object.Heap).Values()
+				} else {
+					values = a.(*object.Deque).Values()
+				}
+				ret.(*object.Array).Items = make([]object.Object, len(values))
+				for i, v := range values {
+					ret.(*object.Array).Items[i] = &object.Array{Items: []object.Object{&object.Number{Value: i}, v}}
+				}
+			case object.GRID:
+				g := a.(*object.Grid)
+				ret.(*object.Array).Items = make([]object.Object, len(g.Cells))
+				for i, v := range g.Cells {
+					ret.(*object.Array).Items[i] = &object.Array{Items: []object.Object{pointObject(g.Point(i)), v}}
+				}
+			case object.STRING:
+				i := 0
+				for _, r := range a.(*object.String).Value {
+					ret.(*object.Array).Items = append(ret.(*object.Array).Items, &object.Array{Items: []object.Object{&object.Number{Value: i}, &object.String{Value: string(r)}}})
+					i++
+				}
+			case object.RANGE:
+				r := a.(*object.Range)
+				ret.(*object.Array).Items = make([]object.Object, r.Len())
+				for i := range ret.(*object.Array).Items {
+					ret.(*object.Array).Items[i] = &object.Array{Items: []object.Object{&object.Number{Value: i}, &object.Number{Value: r.At(i)}}}
+				}
 			default:
-				return &object.Error{Msg: "array or map expected, got: " + a.Type().String()}
+				return &object.Error{Msg: "array, map, string, range, heap, deque or grid expected, got: " + a.Type().String()}
 			}
 			return &object.ReturnObject{Obj: ret}
 		},
 	},
+	// for loops compiled for the VM go over an iterator, the names can't be written in programs
+	"!iterate": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			it := Iterator(args["c"])
+			if object.IsError(it) {
+				return it
+			}
+			return &object.ReturnObject{Obj: it}
+		},
+	},
+	"!next": { // the next [index, value] pair, or null at the end
+		Arguments: []string{"it"},
+		Body: func(args map[string]object.Object) object.Object {
+			it, ok := args["it"].(*object.Iterator)
+			if !ok {
+				return &object.Error{Msg: "iterator expected, got: " + args["it"].Type().String()}
+			}
+			index, value := it.Next()
+			if value == nil {
+				return &object.ReturnObject{Obj: &object.StaticNull}
+			}
+			if object.IsError(value) {
+				return value
+			}
+			return &object.ReturnObject{Obj: &object.Array{Items: []object.Object{index, value}}}
+		},
+	},
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"ryanlang/object"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 func expectNoErr(args ...object.Object) object.Object {
@@ -101,6 +103,21 @@ func Mod(left object.Object, right object.Object) object.Object {
 		return &object.Error{Msg: fmt.Sprintf("incompatible types for the mod operator: %s, %s", left.Type().String(), right.Type().String())}
 	}
 }
+func Pow(left object.Object, right object.Object) object.Object {
+	if e := expectNoErr(left, right); e != nil {
+		return e
+	}
+
+	if left.Type() == object.NUMBER && right.Type() == object.NUMBER {
+		ret, err := pow(left.(*object.Number).Value, right.(*object.Number).Value)
+		if err != nil {
+			return &object.Error{Msg: "pow operator: " + err.Error()}
+		}
+		return &object.Number{Value: ret}
+	} else {
+		return &object.Error{Msg: fmt.Sprintf("incompatible types for the pow operator: %s, %s", left.Type().String(), right.Type().String())}
+	}
+}
 func Gt(left object.Object, right object.Object) object.Object {
 	if e := expectNoErr(left, right); e != nil {
 		return e
@@ -172,6 +189,112 @@ func EqTest(left object.Object, right object.Object) object.Object {
 	}
 	return &object.Error{Msg: "don't know how to compare values of type " + left.Type().String()}
 }
+func In(needle object.Object, container object.Object) object.Object {
+	if e := expectNoErr(needle, container); e != nil {
+		return e
+	}
+
+	var items []object.Object
+	switch container := container.(type) {
+	case *object.Array:
+		items = container.Items
+	case *object.Tuple:
+		items = container.Values
+	case *object.Map:
+		if needle = expectHashable(needle); object.IsError(needle) {
+			return needle
+		}
+		_, ok := container.Fields[needle.(object.Hashable).Hash()]
+		return object.StaticBool(ok)
+	case *object.String:
+		if needle = expect(needle, object.STRING); object.IsError(needle) {
+			return needle
+		}
+		return object.StaticBool(strings.Contains(container.Value, needle.(*object.String).Value))
+	case *object.Heap:
+		items = container.Values()
+	case *object.Deque:
+		items = container.Values()
+	case *object.Grid:
+		items = container.Cells
+	case *object.Range:
+		n, ok := needle.(*object.Number)
+		if !ok {
+			return &object.StaticFalse
+		}
+		_, found := container.Index(n.Value)
+		return object.StaticBool(found)
+	default:
+		return &object.Error{Msg: "operator in is not supported on this type: " + container.Type().String()}
+	}
+
+	// hashable values, including arrays, are compared by their hashes, EqTest only knows the scalar types
+	needleHash, hashable := hash(needle)
+	for _, item := range items {
+		if hashable {
+			if itemHash, ok := hash(item); ok {
+				if itemHash == needleHash {
+					return &object.StaticTrue
+				}
+				continue
+			}
+		}
+		eq := EqTest(needle, item)
+		if object.IsError(eq) {
+			return eq
+		}
+		if eq.(*object.Boolean).Value {
+			return &object.StaticTrue
+		}
+	}
+	return &object.StaticFalse
+}
+
+// hash returns the hash of a value if it has one, arrays only have it when all their items do
+func hash(obj object.Object) (string, bool) {
+	if arr, ok := obj.(*object.Array); ok {
+		for _, item := range arr.Items {
+			if _, ok := hash(item); !ok {
+				return "", false
+			}
+		}
+	}
+	h, ok := obj.(object.Hashable)
+	if !ok {
+		return "", false
+	}
+	return h.Hash(), true
+}
+
+// index converts a possibly negative (counted from the end) index into an index from the beginning
+func index(i int, length int) int {
+	if i < 0 {
+		return length + i
+	}
+	return i
+}
+
+// charAt returns the i-th character of the string, counting runes rather than bytes. A negative index counts from
+// the end. ok is false if the index is out of range.
+func charAt(s string, i int) (ch string, ok bool) {
+	if i < 0 {
+		i += utf8.RuneCountInString(s)
+	}
+	if i < 0 {
+		return "", false
+	}
+	for pos := 0; pos < len(s); i-- {
+		size := 1
+		if s[pos] >= utf8.RuneSelf {
+			_, size = utf8.DecodeRuneInString(s[pos:])
+		}
+		if i == 0 {
+			return s[pos : pos+size], true
+		}
+		pos += size
+	}
+	return "", false
+}
 func FieldAccess(lval object.Object, rval object.Object) object.Object {
 	if e := expectNoErr(lval, rval); e != nil {
 		return e
@@ -211,22 +334,21 @@ func FieldAccess(lval object.Object, rval object.Object) object.Object {
 		if rval = expect(rval, object.NUMBER); object.IsError(rval) {
 			return rval
 		}
-		index := rval.(*object.Number).Value
+		i := index(rval.(*object.Number).Value, len(lval.(*object.Array).Items))
 
-		if index >= len(lval.(*object.Array).Items) || index < 0 {
-			return &object.Error{Msg: "index out of range: " + strconv.Itoa(index)}
+		if i >= len(lval.(*object.Array).Items) || i < 0 {
+			return &object.Error{Msg: "index out of range: " + strconv.Itoa(rval.(*object.Number).Value)}
 		}
-		val = lval.(*object.Array).Items[index]
+		val = lval.(*object.Array).Items[i]
 	} else if lval.Type() == object.STRING {
 		if rval = expect(rval, object.NUMBER); object.IsError(rval) {
 			return rval
 		}
-		index := rval.(*object.Number).Value
-
-		if index >= len(lval.(*object.String).Value) || index < 0 {
-			return &object.Error{Msg: "index out of range: " + strconv.Itoa(index)}
+		ch, ok := charAt(lval.(*object.String).Value, rval.(*object.Number).Value)
+		if !ok {
+			return &object.Error{Msg: "index out of range: " + strconv.Itoa(rval.(*object.Number).Value)}
 		}
-		val = &object.String{Value: string(lval.(*object.String).Value[index])}
+		val = &object.String{Value: ch}
 	} else {
 		return &object.Error{Msg: "field access operator is not supported on this type: " + lval.Type().String()}
 	}
@@ -237,6 +359,9 @@ func FieldAssign(lval object.Object, rval object.Object, value object.Object) ob
 	if e := expectNoErr(lval, rval, value); e != nil {
 		return e
 	}
+	if object.IsFrozen(lval) {
+		return &object.Error{Msg: "cannot assign to a field of a frozen " + lval.Type().String()}
+	}
 
 	if lval.Type() == object.STRUCT {
 		if rval = expect(rval, object.STRING); object.IsError(rval) {
@@ -275,19 +400,19 @@ func FieldAssign(lval object.Object, rval object.Object, value object.Object) ob
 		if rval = expect(rval, object.NUMBER); object.IsError(rval) {
 			return rval
 		}
-		index := rval.(*object.Number).Value
-		if index >= len(lval.(*object.Array).Items) || index < 0 {
+		i := index(rval.(*object.Number).Value, len(lval.(*object.Array).Items))
+		if i >= len(lval.(*object.Array).Items) || i < 0 {
 			return &object.Error{
-				Msg: "cannot assign to an array index out of range: " + strconv.Itoa(index),
+				Msg: "cannot assign to an array index out of range: " + strconv.Itoa(rval.(*object.Number).Value),
 			}
 		}
-		currentValue := lval.(*object.Array).Items[index]
+		currentValue := lval.(*object.Array).Items[i]
 		if currentValue.Type() != value.Type() {
 			return &object.Error{
 				Msg: "array item already holds a value of type " + currentValue.Type().String() + ", got: " + value.Type().String(),
 			}
 		}
-		lval.(*object.Array).Items[index] = value
+		lval.(*object.Array).Items[i] = value
 	} else {
 		return &object.Error{Msg: "unexpected field access assign type: " + lval.Type().String()}
 	}
@@ -0,0 +1,218 @@
+package funcs
+
+import (
+	"ryanlang/object"
+)
+
+// heapLess returns a function telling whether the i-th item of the heap goes before the j-th one
+func heapLess(ctx Context, h *object.Heap) func(i int, j int) (bool, error) {
+	cmp := compare
+	if h.Cmp.Type() != object.NULL {
+		cmp = comparator(ctx, h.Cmp)
+	}
+	return func(i int, j int) (bool, error) {
+		c, err := cmp(h.Items[i].OrderKey(), h.Items[j].OrderKey())
+		return c < 0, err
+	}
+}
+
+func heapUp(h *object.Heap, i int, less func(i int, j int) (bool, error)) error {
+	for i > 0 {
+		parent := (i - 1) / 2
+		ok, err := less(i, parent)
+		if err != nil || !ok {
+			return err
+		}
+		h.Items[i], h.Items[parent] = h.Items[parent], h.Items[i]
+		i = parent
+	}
+	return nil
+}
+
+func heapDown(h *object.Heap, i int, less func(i int, j int) (bool, error)) error {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child >= len(h.Items) {
+				continue
+			}
+			ok, err := less(child, smallest)
+			if err != nil {
+				return err
+			}
+			if ok {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return nil
+		}
+		h.Items[i], h.Items[smallest] = h.Items[smallest], h.Items[i]
+		i = smallest
+	}
+}
+
+func collectionArg(args map[string]object.Object, name string) (object.Object, object.Object) {
+	switch c := args[name].(type) {
+	case *object.Heap, *object.Deque:
+		return c, nil
+	}
+	return nil, argError(name, "heap or deque", args[name])
+}
+
+func dequeArg(args map[string]object.Object, name string) (*object.Deque, object.Object) {
+	d, ok := args[name].(*object.Deque)
+	if !ok {
+		return nil, argError(name, "deque", args[name])
+	}
+	return d, nil
+}
+
+func emptyError(c object.Object) object.Object {
+	return &object.Error{Msg: "the " + c.Type().String() + " is empty"}
+}
+
+var heapBuiltin = BuiltinFunction{
+	Arguments: []string{"cmp"},
+	Optional:  1,
+	Body: func(args map[string]object.Object) object.Object {
+		switch args["cmp"].(type) {
+		case *object.Null, *object.Closure, *object.Function:
+		default:
+			return argError("cmp", "function", args["cmp"])
+		}
+		return returnValue(&object.Heap{Cmp: args["cmp"]})
+	},
+}
+
+var dequeBuiltin = BuiltinFunction{
+	Arguments: []string{},
+	Body: func(args map[string]object.Object) object.Object {
+		return returnValue(&object.Deque{})
+	},
+}
+
+// pushBuiltin adds an item to a heap, optionally with a key it is ordered by, or to the back of a deque
+var pushBuiltin = BuiltinFunction{
+	Arguments: []string{"c", "item", "key"},
+	Optional:  1,
+	ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+		c, err := collectionArg(args, "c")
+		if err != nil {
+			return err
+		}
+		switch c := c.(type) {
+		case *object.Heap:
+			item := object.HeapItem{Value: args["item"]}
+			if args["key"].Type() != object.NULL {
+				item.Key = args["key"]
+			}
+			c.Items = append(c.Items, item)
+			if err := heapUp(c, len(c.Items)-1, heapLess(ctx, c)); err != nil {
+				return &object.Error{Msg: err.Error()}
+			}
+		case *object.Deque:
+			if args["key"].Type() != object.NULL {
+				return &object.Error{Msg: "argument key: deque items are not ordered by a key"}
+			}
+			c.PushBack(args["item"])
+		}
+		return returnValue(&object.StaticNull)
+	},
+}
+
+// popBuiltin removes and returns the smallest item of a heap or the last item of a deque
+var popBuiltin = BuiltinFunction{
+	Arguments: []string{"c"},
+	ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+		c, err := collectionArg(args, "c")
+		if err != nil {
+			return err
+		}
+		switch c := c.(type) {
+		case *object.Heap:
+			if len(c.Items) == 0 {
+				return emptyError(c)
+			}
+			top := c.Items[0]
+			last := len(c.Items) - 1
+			c.Items[0] = c.Items[last]
+			c.Items[last] = object.HeapItem{}
+			c.Items = c.Items[:last]
+			if err := heapDown(c, 0, heapLess(ctx, c)); err != nil {
+				return &object.Error{Msg: err.Error()}
+			}
+			return returnValue(top.Value)
+		case *object.Deque:
+			if c.Len() == 0 {
+				return emptyError(c)
+			}
+			return returnValue(c.PopBack())
+		}
+		return nil
+	},
+}
+
+// peekBuiltin returns the smallest item of a heap or the last item of a deque without removing it
+var peekBuiltin = BuiltinFunction{
+	Arguments: []string{"c"},
+	Body: func(args map[string]object.Object) object.Object {
+		c, err := collectionArg(args, "c")
+		if err != nil {
+			return err
+		}
+		switch c := c.(type) {
+		case *object.Heap:
+			if len(c.Items) == 0 {
+				return emptyError(c)
+			}
+			return returnValue(c.Items[0].Value)
+		case *object.Deque:
+			if c.Len() == 0 {
+				return emptyError(c)
+			}
+			return returnValue(c.At(c.Len() - 1))
+		}
+		return nil
+	},
+}
+
+var pushFrontBuiltin = BuiltinFunction{
+	Arguments: []string{"d", "item"},
+	Body: func(args map[string]object.Object) object.Object {
+		d, err := dequeArg(args, "d")
+		if err != nil {
+			return err
+		}
+		d.PushFront(args["item"])
+		return returnValue(&object.StaticNull)
+	},
+}
+
+var popFrontBuiltin = BuiltinFunction{
+	Arguments: []string{"d"},
+	Body: func(args map[string]object.Object) object.Object {
+		d, err := dequeArg(args, "d")
+		if err != nil {
+			return err
+		}
+		if d.Len() == 0 {
+			return emptyError(d)
+		}
+		return returnValue(d.PopFront())
+	},
+}
+
+var peekFrontBuiltin = BuiltinFunction{
+	Arguments: []string{"d"},
+	Body: func(args map[string]object.Object) object.Object {
+		d, err := dequeArg(args, "d")
+		if err != nil {
+			return err
+		}
+		if d.Len() == 0 {
+			return emptyError(d)
+		}
+		return returnValue(d.At(0))
+	},
+}
@@ -0,0 +1,57 @@
+package funcs
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"ryanlang/object"
+)
+
+// digestFunc makes a built-in function hashing the bytes of a string, the digest is returned in hex
+func digestFunc(sum func(b []byte) []byte) BuiltinFunction {
+	return stringFunc(func(s string) object.Object {
+		return &object.String{Value: hex.EncodeToString(sum([]byte(s)))}
+	})
+}
+
+var cryptoModule = map[string]BuiltinFunction{
+	"md5": digestFunc(func(b []byte) []byte {
+		sum := md5.Sum(b)
+		return sum[:]
+	}),
+	"sha1": digestFunc(func(b []byte) []byte {
+		sum := sha1.Sum(b)
+		return sum[:]
+	}),
+	"sha256": digestFunc(func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:]
+	}),
+}
+
+// decodeFunc makes a built-in function decoding a string into the bytes of another string
+func decodeFunc(decode func(s string) ([]byte, error)) BuiltinFunction {
+	return stringFunc(func(s string) object.Object {
+		b, err := decode(s)
+		if err != nil {
+			return &object.Error{Msg: err.Error()}
+		}
+		return &object.String{Value: string(b)}
+	})
+}
+
+var hexModule = map[string]BuiltinFunction{
+	"encode": stringFunc(func(s string) object.Object {
+		return &object.String{Value: hex.EncodeToString([]byte(s))}
+	}),
+	"decode": decodeFunc(hex.DecodeString),
+}
+
+var base64Module = map[string]BuiltinFunction{
+	"encode": stringFunc(func(s string) object.Object {
+		return &object.String{Value: base64.StdEncoding.EncodeToString([]byte(s))}
+	}),
+	"decode": decodeFunc(base64.StdEncoding.DecodeString),
+}
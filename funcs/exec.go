@@ -0,0 +1,117 @@
+package funcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"ryanlang/object"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Policy restricts what a program can do to the host, the zero value allows everything
+type Policy struct {
+	// Sandbox disables exec unless AllowExec is set
+	Sandbox   bool
+	AllowExec bool
+}
+
+// CanExec reports whether the program may run subprocesses
+func (p Policy) CanExec() bool {
+	return !p.Sandbox || p.AllowExec
+}
+
+// commandLine is how a command is shown in error messages, arguments with spaces are quoted
+func commandLine(name string, args []string) string {
+	parts := []string{name}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+var execBuiltin = BuiltinFunction{
+	Arguments: []string{"cmd", "args", "stdin", "timeout"},
+	Optional:  2,
+	ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+		name, err := stringArg(args, "cmd")
+		if err != nil {
+			return err
+		}
+		items, err := arrayArg(args, "args")
+		if err != nil {
+			return err
+		}
+		cmdArgs := make([]string, len(items))
+		for i, item := range items {
+			s, ok := item.(*object.String)
+			if !ok {
+				return argError("args", "array of strings", item)
+			}
+			cmdArgs[i] = s.Value
+		}
+		line := commandLine(name, cmdArgs)
+		if !ctx.Policy().CanExec() {
+			return &object.Error{Msg: "exec " + line + ": running subprocesses is disabled by the sandbox policy"}
+		}
+
+		var timeout time.Duration
+		if args["timeout"].Type() != object.NULL {
+			ms, err := sizeArg(args, "timeout")
+			if err != nil {
+				return err
+			}
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+
+		cmd := exec.Command(name, cmdArgs...)
+		switch stdin := args["stdin"].(type) {
+		case *object.Null:
+		case *object.String:
+			cmd.Stdin = strings.NewReader(stdin.Value)
+		default:
+			return argError("stdin", "string", stdin)
+		}
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+		// the command runs in its own process group, so a timeout also kills the processes it started:
+		// they would keep the output pipes open and the wait would last until they end
+		setProcessGroup(cmd)
+		if startErr := cmd.Start(); startErr != nil {
+			return &object.Error{Msg: fmt.Sprintf("exec %s: %s", line, startErr)}
+		}
+		var timedOut atomic.Bool
+		if args["timeout"].Type() != object.NULL {
+			timer := time.AfterFunc(timeout, func() {
+				timedOut.Store(true)
+				killProcessGroup(cmd)
+			})
+			defer timer.Stop()
+		}
+
+		code := 0
+		if runErr := cmd.Wait(); runErr != nil {
+			var exitErr *exec.ExitError
+			switch {
+			case timedOut.Load():
+				return &object.Error{Msg: fmt.Sprintf("exec %s: timed out after %s", line, timeout)}
+			case errors.As(runErr, &exitErr) && exitErr.Exited():
+				// a non-zero exit code is a result rather than an error
+				code = exitErr.ExitCode()
+			default:
+				return &object.Error{Msg: fmt.Sprintf("exec %s: %s", line, runErr)}
+			}
+		}
+		return returnValue(&object.Struct{Fields: map[string]object.Object{
+			"stdout": &object.String{Value: stdout.String()},
+			"stderr": &object.String{Value: stderr.String()},
+			"code":   &object.Number{Value: code},
+		}})
+	},
+}
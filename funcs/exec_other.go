@@ -0,0 +1,13 @@
+//go:build !unix
+
+package funcs
+
+import "os/exec"
+
+// setProcessGroup does nothing where there are no process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills only the started command where there are no process groups
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,253 @@
+package funcs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"ryanlang/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatSpec is what follows the colon in a placeholder: [[fill]align][+][#][0][width][type]
+type formatSpec struct {
+	fill  rune
+	align rune // '<', '>' or '^', 0 for the default one: numbers to the right, everything else to the left
+	sign  bool
+	alt   bool // prefix hex, octal and binary numbers with 0x, 0o or 0b
+	zero  bool // pad numbers with zeros after the sign
+	width int
+	typ   rune
+}
+
+// maxFormatWidth limits the width in format specs, the padding is allocated at once
+const maxFormatWidth = 1 << 20
+
+func parseFormatSpec(s string) (formatSpec, error) {
+	spec := formatSpec{fill: ' '}
+	runes := []rune(s)
+	isAlign := func(r rune) bool { return r == '<' || r == '>' || r == '^' }
+	if len(runes) >= 2 && isAlign(runes[1]) {
+		spec.fill, spec.align, runes = runes[0], runes[1], runes[2:]
+	} else if len(runes) >= 1 && isAlign(runes[0]) {
+		spec.align, runes = runes[0], runes[1:]
+	}
+	if len(runes) > 0 && runes[0] == '+' {
+		spec.sign, runes = true, runes[1:]
+	}
+	if len(runes) > 0 && runes[0] == '#' {
+		spec.alt, runes = true, runes[1:]
+	}
+	if len(runes) > 0 && runes[0] == '0' {
+		spec.zero, runes = true, runes[1:]
+	}
+	for len(runes) > 0 && runes[0] >= '0' && runes[0] <= '9' {
+		spec.width, runes = spec.width*10+int(runes[0]-'0'), runes[1:]
+		if spec.width > maxFormatWidth {
+			return spec, fmt.Errorf("format width is larger than %d: %s", maxFormatWidth, s)
+		}
+	}
+	if len(runes) > 0 {
+		spec.typ, runes = runes[0], runes[1:]
+		if !strings.ContainsRune("sqdxXob", spec.typ) {
+			return spec, fmt.Errorf("unknown format type: %c", spec.typ)
+		}
+	}
+	if len(runes) > 0 {
+		return spec, fmt.Errorf("invalid format spec: %s", s)
+	}
+	return spec, nil
+}
+
+// formatNumber renders a number in the base requested by the spec, returning the sign and the prefix separately
+// so that zero padding goes between them and the digits
+func formatNumber(n int, spec formatSpec) (sign string, digits string) {
+	base, prefix := 10, ""
+	switch spec.typ {
+	case 'x', 'X':
+		base, prefix = 16, "0x"
+	case 'o':
+		base, prefix = 8, "0o"
+	case 'b':
+		base, prefix = 2, "0b"
+	}
+	if n < 0 {
+		sign = "-"
+	} else if spec.sign {
+		sign = "+"
+	}
+	// the magnitude is formatted as unsigned, so that the smallest int doesn't overflow
+	magnitude := uint64(n)
+	if n < 0 {
+		magnitude = -magnitude
+	}
+	digits = strconv.FormatUint(magnitude, base)
+	if spec.typ == 'X' {
+		digits, prefix = strings.ToUpper(digits), "0X"
+	}
+	if spec.alt {
+		sign += prefix
+	}
+	return sign, digits
+}
+
+func formatValue(v object.Object, spec formatSpec) (string, error) {
+	var s string
+	n, isNumber := v.(*object.Number)
+	switch spec.typ {
+	case 'd', 'x', 'X', 'o', 'b':
+		if !isNumber {
+			return "", fmt.Errorf("format type %c requires a number, got: %s", spec.typ, v.Type().String())
+		}
+	case 'q':
+		s, isNumber = v.String(), false
+	}
+	if spec.typ != 'q' {
+		if str, ok := v.(*object.String); ok {
+			s = str.Value
+		} else if !isNumber {
+			s = v.String()
+		}
+	}
+
+	pad := spec.width
+	if isNumber {
+		sign, digits := formatNumber(n.Value, spec)
+		pad -= utf8.RuneCountInString(sign) + len(digits)
+		if spec.zero && spec.align == 0 && pad > 0 {
+			return sign + strings.Repeat("0", pad) + digits, nil
+		}
+		s = sign + digits
+	} else {
+		pad -= utf8.RuneCountInString(s)
+		if spec.zero && spec.align == 0 {
+			spec.fill = '0'
+		}
+	}
+	if pad <= 0 {
+		return s, nil
+	}
+
+	align := spec.align
+	if align == 0 {
+		align = '<'
+		if isNumber {
+			align = '>'
+		}
+	}
+	fill := string(spec.fill)
+	switch align {
+	case '>':
+		return strings.Repeat(fill, pad) + s, nil
+	case '^':
+		return strings.Repeat(fill, pad/2) + s + strings.Repeat(fill, pad-pad/2), nil
+	}
+	return s + strings.Repeat(fill, pad), nil
+}
+
+// format substitutes the arguments into the placeholders of the template: {} takes the next argument, {1} the
+// second one, and {:spec} or {1:spec} formats it as described by the spec. Literal braces are written as {{ and }}.
+func format(template string, args []object.Object) (string, error) {
+	var sb strings.Builder
+	used := make([]bool, len(args))
+	next := 0
+	for len(template) > 0 {
+		i := strings.IndexAny(template, "{}")
+		if i < 0 {
+			sb.WriteString(template)
+			break
+		}
+		sb.WriteString(template[:i])
+		if template[i] == '}' {
+			if !strings.HasPrefix(template[i:], "}}") {
+				return "", errors.New("single } in the format string, use }} for a literal one")
+			}
+			sb.WriteByte('}')
+			template = template[i+2:]
+			continue
+		}
+		if strings.HasPrefix(template[i:], "{{") {
+			sb.WriteByte('{')
+			template = template[i+2:]
+			continue
+		}
+		end := strings.IndexByte(template[i:], '}')
+		if end < 0 {
+			return "", errors.New("unclosed { in the format string, use {{ for a literal one")
+		}
+		placeholder := template[i+1 : i+end]
+		template = template[i+end+1:]
+
+		index, specStr, _ := strings.Cut(placeholder, ":")
+		n := next
+		if index != "" {
+			var err error
+			if n, err = strconv.Atoi(index); err != nil || n < 0 {
+				return "", fmt.Errorf("invalid placeholder: {%s}", placeholder)
+			}
+		} else {
+			next++
+		}
+		if n >= len(args) {
+			return "", fmt.Errorf("placeholder {%s} refers to argument %d, but there are %d", placeholder, n, len(args))
+		}
+		spec, err := parseFormatSpec(specStr)
+		if err != nil {
+			return "", err
+		}
+		s, err := formatValue(args[n], spec)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s)
+		used[n] = true
+	}
+	for i, ok := range used {
+		if !ok {
+			return "", fmt.Errorf("argument %d is not used in the format string", i)
+		}
+	}
+	return sb.String(), nil
+}
+
+var formatBuiltin = BuiltinFunction{
+	Arguments: []string{"f", "args"},
+	Variadic:  true,
+	Body: func(args map[string]object.Object) object.Object {
+		f, err := stringArg(args, "f")
+		if err != nil {
+			return err
+		}
+		s, formatErr := format(f, args["args"].(*object.Array).Items)
+		if formatErr != nil {
+			return &object.Error{Msg: formatErr.Error()}
+		}
+		return returnValue(&object.String{Value: s})
+	},
+}
+
+// printFunc makes a built-in function printing a value to one of the outputs, strings are quoted unless raw is true
+func printFunc(output func(ctx Context) io.Writer, newline bool) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"s", "raw"},
+		Optional:  1,
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			s := args["s"].String()
+			switch raw := args["raw"].(type) {
+			case *object.Null:
+			case *object.Boolean:
+				if str, ok := args["s"].(*object.String); ok && raw.Value {
+					s = str.Value
+				}
+			default:
+				return argError("raw", "bool", raw)
+			}
+			if newline {
+				s += "\n"
+			}
+			fmt.Fprint(output(ctx), s)
+			return returnValue(&object.StaticNull)
+		},
+	}
+}
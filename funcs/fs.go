@@ -0,0 +1,144 @@
+package funcs
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"ryanlang/object"
+	"strings"
+)
+
+// Stdin is shared by all the functions reading the standard input, so that none of them loses buffered input.
+// It's a variable so that the host can provide the input.
+var Stdin = bufio.NewReader(os.Stdin)
+
+// readLine reads the next line without the line terminator, ok is false at the end of the input
+func readLine(r *bufio.Reader) (line string, ok bool, err error) {
+	line, err = r.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return "", false, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, true, nil
+}
+
+// writeFile makes a built-in function writing a string to a file
+func writeFile(flag int) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"fn", "s"},
+		Body: func(args map[string]object.Object) object.Object {
+			fn, err := stringArg(args, "fn")
+			if err != nil {
+				return err
+			}
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			f, openErr := os.OpenFile(fn, flag, 0644)
+			if openErr != nil {
+				return &object.Error{Msg: openErr.Error()}
+			}
+			_, writeErr := f.WriteString(s)
+			closeErr := f.Close()
+			if writeErr != nil {
+				return &object.Error{Msg: writeErr.Error()}
+			}
+			if closeErr != nil {
+				return &object.Error{Msg: closeErr.Error()}
+			}
+			return returnValue(&object.StaticNull)
+		},
+	}
+}
+
+var fsModule = map[string]BuiltinFunction{
+	"readfile": {
+		Arguments: []string{"fn"},
+		Body: func(args map[string]object.Object) object.Object {
+			fn, err := stringArg(args, "fn")
+			if err != nil {
+				return err
+			}
+			b, readErr := os.ReadFile(fn)
+			if readErr != nil {
+				return &object.Error{Msg: readErr.Error()}
+			}
+			return returnValue(&object.String{Value: string(b)})
+		},
+	},
+	"writefile":  writeFile(os.O_WRONLY | os.O_CREATE | os.O_TRUNC),
+	"appendfile": writeFile(os.O_WRONLY | os.O_CREATE | os.O_APPEND),
+	"exists": {
+		Arguments: []string{"fn"},
+		Body: func(args map[string]object.Object) object.Object {
+			fn, err := stringArg(args, "fn")
+			if err != nil {
+				return err
+			}
+			_, statErr := os.Stat(fn)
+			if statErr != nil && !os.IsNotExist(statErr) {
+				return &object.Error{Msg: statErr.Error()}
+			}
+			return returnValue(object.StaticBool(statErr == nil))
+		},
+	},
+	"listdir": {
+		Arguments: []string{"dir"},
+		Body: func(args map[string]object.Object) object.Object {
+			dir, err := stringArg(args, "dir")
+			if err != nil {
+				return err
+			}
+			entries, readErr := os.ReadDir(dir) // sorted by name
+			if readErr != nil {
+				return &object.Error{Msg: readErr.Error()}
+			}
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+			return returnValue(stringsArray(names))
+		},
+	},
+}
+
+var stdinModule = map[string]BuiltinFunction{
+	"readline": {
+		Arguments: []string{},
+		Body: func(args map[string]object.Object) object.Object {
+			line, ok, err := readLine(Stdin)
+			if err != nil {
+				return &object.Error{Msg: err.Error()}
+			}
+			if !ok {
+				return returnValue(&object.StaticNull)
+			}
+			return returnValue(&object.String{Value: line})
+		},
+	},
+	"lines": { // streamed: every step of the loop reads the next line, so they can only be iterated once
+		Arguments: []string{},
+		Body: func(args map[string]object.Object) object.Object {
+			i := 0
+			return returnValue(&object.Iterator{Next: func() (object.Object, object.Object) {
+				line, ok, err := readLine(Stdin)
+				if err != nil {
+					return nil, &object.Error{Msg: err.Error()}
+				}
+				if !ok {
+					return nil, nil
+				}
+				i++
+				return &object.Number{Value: i - 1}, &object.String{Value: line}
+			}})
+		},
+	},
+}
@@ -0,0 +1,501 @@
+package funcs
+
+import (
+	"container/heap"
+	"ryanlang/object"
+)
+
+// edge leads to a neighbor of a node, cost is 1 in unweighted graphs
+type edge struct {
+	node object.Object
+	cost int
+}
+
+// graph is either an adjacency map (node => neighbors) or a function returning the neighbors of a node. Neighbors
+// are given as an array of nodes, or, in weighted graphs, as an array of [node, cost] pairs or a map node => cost.
+type graph struct {
+	ctx      Context
+	adj      object.Object
+	weighted bool
+	edges    map[string][]edge // the function is called once per node
+}
+
+func graphArg(ctx Context, args map[string]object.Object, name string, weighted bool) (*graph, object.Object) {
+	switch args[name].(type) {
+	case *object.Map, *object.Closure, *object.Function:
+		return &graph{ctx: ctx, adj: args[name], weighted: weighted, edges: map[string][]edge{}}, nil
+	}
+	return nil, argError(name, "map or function", args[name])
+}
+
+func nodeHash(node object.Object) (string, object.Object) {
+	h, ok := node.(object.Hashable)
+	if !ok {
+		return "", &object.Error{Msg: "graph nodes must be hashable, got: " + node.Type().String()}
+	}
+	return h.Hash(), nil
+}
+
+func (g *graph) neighbors(node object.Object) ([]edge, object.Object) {
+	hash, err := nodeHash(node)
+	if err != nil {
+		return nil, err
+	}
+	if edges, ok := g.edges[hash]; ok {
+		return edges, nil
+	}
+
+	var list object.Object
+	if m, ok := g.adj.(*object.Map); ok {
+		item, ok := m.Fields[hash]
+		if !ok {
+			return nil, nil
+		}
+		list = item.Value
+	} else if list, err = call(g.ctx, g.adj, node); err != nil {
+		return nil, err
+	}
+
+	var edges []edge
+	switch list := list.(type) {
+	case *object.Array:
+		for _, item := range list.Items {
+			e := edge{node: item, cost: 1}
+			if g.weighted {
+				pair, ok := item.(*object.Array)
+				if !ok || len(pair.Items) != 2 {
+					return nil, &object.Error{Msg: "[node, cost] pairs expected as neighbors, got: " + item.String()}
+				}
+				e.node = pair.Items[0]
+				if e.cost, err = edgeCost(pair.Items[1]); err != nil {
+					return nil, err
+				}
+			}
+			edges = append(edges, e)
+		}
+	case *object.Map:
+		for _, item := range sortedMapItems(list) {
+			e := edge{node: item.Key, cost: 1}
+			if g.weighted {
+				if e.cost, err = edgeCost(item.Value); err != nil {
+					return nil, err
+				}
+			}
+			edges = append(edges, e)
+		}
+	case *object.Null:
+	default:
+		return nil, &object.Error{Msg: "neighbors must be an array or a map, got: " + list.Type().String()}
+	}
+	g.edges[hash] = edges
+	return edges, nil
+}
+
+func edgeCost(cost object.Object) (int, object.Object) {
+	n, ok := cost.(*object.Number)
+	if !ok {
+		return 0, &object.Error{Msg: "edge cost must be a number, got: " + cost.Type().String()}
+	}
+	return n.Value, nil
+}
+
+// nodes lists the given nodes, then the keys of an adjacency map, then the nodes reachable from them
+func (g *graph) nodes(given object.Object) ([]object.Object, object.Object) {
+	var ret []object.Object
+	switch given := given.(type) {
+	case *object.Array:
+		ret = append(ret, given.Items...)
+	case *object.Null:
+		if _, ok := g.adj.(*object.Map); !ok {
+			return nil, &object.Error{Msg: "argument nodes: array expected for a graph given as a function"}
+		}
+	default:
+		return nil, argError("nodes", "array", given)
+	}
+	if m, ok := g.adj.(*object.Map); ok {
+		for _, item := range sortedMapItems(m) {
+			ret = append(ret, item.Key)
+		}
+	}
+
+	seen := map[string]bool{}
+	var unique []object.Object
+	for len(ret) > 0 {
+		node := ret[0]
+		ret = ret[1:]
+		hash, err := nodeHash(node)
+		if err != nil {
+			return nil, err
+		}
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		unique = append(unique, node)
+		edges, err := g.neighbors(node)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			ret = append(ret, e.node)
+		}
+	}
+	return unique, nil
+}
+
+type queueItem struct {
+	node     object.Object
+	dist     int
+	priority int
+	seq      int // nodes of the same priority are visited in the order they were found
+}
+type queue []queueItem
+
+func (q queue) Len() int { return len(q) }
+func (q queue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func newMap() *object.Map {
+	return &object.Map{Fields: map[string]object.MapItem{}}
+}
+
+// search finds the shortest paths from start, stopping at the goal, which is a node or a predicate, if there is one.
+// Without a heuristic this is Dijkstra's algorithm, which visits the nodes of an unweighted graph in the BFS order.
+func search(g *graph, start object.Object, goal object.Object, heuristic object.Object) object.Object {
+	dist, prev := newMap(), newMap()
+	best := map[string]int{}
+	ret := &object.Struct{Fields: map[string]object.Object{
+		"dist": dist,
+		"prev": prev,
+		"path": &object.StaticNull,
+		"cost": &object.StaticNull,
+	}}
+
+	priority := func(node object.Object, d int) (int, object.Object) {
+		if heuristic == nil {
+			return d, nil
+		}
+		h, err := call(g.ctx, heuristic, node)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := h.(*object.Number)
+		if !ok {
+			return 0, &object.Error{Msg: "heuristic must return a number, got: " + h.Type().String()}
+		}
+		return d + n.Value, nil
+	}
+	isGoal := func(node object.Object, hash string) (bool, object.Object) {
+		switch goal := goal.(type) {
+		case *object.Null:
+			return false, nil
+		case *object.Closure, *object.Function:
+			return test(g.ctx, goal, node)
+		}
+		goalHash, err := nodeHash(goal)
+		return goalHash == hash, err
+	}
+
+	startHash, err := nodeHash(start)
+	if err != nil {
+		return err
+	}
+	p, err := priority(start, 0)
+	if err != nil {
+		return err
+	}
+	best[startHash] = 0
+	q := &queue{{node: start, priority: p}}
+	seq := 1
+	for q.Len() > 0 {
+		item := heap.Pop(q).(queueItem)
+		hash, _ := nodeHash(item.node)
+		if _, ok := dist.Fields[hash]; ok || item.dist > best[hash] {
+			continue
+		}
+		dist.Fields[hash] = object.MapItem{Key: item.node, Value: &object.Number{Value: item.dist}}
+
+		found, err := isGoal(item.node, hash)
+		if err != nil {
+			return err
+		}
+		if found {
+			ret.Fields["path"] = shortestPath(dist, prev, item.node)
+			ret.Fields["cost"] = &object.Number{Value: item.dist}
+			break
+		}
+
+		edges, err := g.neighbors(item.node)
+		if err != nil {
+			return err
+		}
+		for _, e := range edges {
+			if e.cost < 0 {
+				return &object.Error{Msg: "negative edge cost: " + item.node.String() + " -> " + e.node.String()}
+			}
+			nextHash, err := nodeHash(e.node)
+			if err != nil {
+				return err
+			}
+			if _, ok := dist.Fields[nextHash]; ok {
+				continue
+			}
+			d := item.dist + e.cost
+			if old, ok := best[nextHash]; ok && old <= d {
+				continue
+			}
+			p, err := priority(e.node, d)
+			if err != nil {
+				return err
+			}
+			best[nextHash] = d
+			prev.Fields[nextHash] = object.MapItem{Key: e.node, Value: item.node}
+			heap.Push(q, queueItem{node: e.node, dist: d, priority: p, seq: seq})
+			seq++
+		}
+	}
+	// predecessors of the nodes which were found, but not visited, may be not the best ones
+	for hash := range prev.Fields {
+		if _, ok := dist.Fields[hash]; !ok {
+			delete(prev.Fields, hash)
+		}
+	}
+	return ret
+}
+
+// shortestPath follows the predecessors back from the node, it returns null if the node was not reached
+func shortestPath(dist *object.Map, prev *object.Map, node object.Object) object.Object {
+	hash, err := nodeHash(node)
+	if err != nil {
+		return err
+	}
+	if _, ok := dist.Fields[hash]; !ok {
+		return &object.StaticNull
+	}
+	var reversed []object.Object
+	for {
+		reversed = append(reversed, node)
+		item, ok := prev.Fields[hash]
+		if !ok {
+			break
+		}
+		node = item.Value
+		hash, _ = nodeHash(node)
+	}
+	ret := &object.Array{Items: make([]object.Object, len(reversed))}
+	for i, node := range reversed {
+		ret.Items[len(reversed)-1-i] = node
+	}
+	return ret
+}
+
+// searchFunc makes a built-in function finding the shortest paths, with a heuristic if the name of its argument
+// is given
+func searchFunc(weighted bool, heuristic string) BuiltinFunction {
+	f := BuiltinFunction{
+		Arguments: []string{"g", "start", "goal"},
+		Optional:  1,
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			g, err := graphArg(ctx, args, "g", weighted)
+			if err != nil {
+				return err
+			}
+			var h object.Object
+			if heuristic != "" {
+				if h, err = funcArg(args, heuristic, false); err != nil {
+					return err
+				}
+			}
+			return returnResult(search(g, args["start"], args["goal"], h))
+		},
+	}
+	if heuristic != "" {
+		// the goal is required, since it's what the heuristic estimates the distance to
+		f.Arguments = append(f.Arguments, heuristic)
+		f.Optional = 0
+	}
+	return f
+}
+
+// wholeGraphFunc makes a built-in function of a whole graph, the nodes must be listed if the graph is a function
+func wholeGraphFunc(weighted bool, f func(g *graph, nodes []object.Object) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"g", "nodes"},
+		Optional:  1,
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			g, err := graphArg(ctx, args, "g", weighted)
+			if err != nil {
+				return err
+			}
+			nodes, err := g.nodes(args["nodes"])
+			if err != nil {
+				return err
+			}
+			return returnResult(f(g, nodes))
+		},
+	}
+}
+
+var graphModule = map[string]BuiltinFunction{
+	"bfs":      searchFunc(false, ""),
+	"dijkstra": searchFunc(true, ""),
+	"astar":    searchFunc(true, "h"),
+	"path": {
+		Arguments: []string{"result", "node"},
+		Body: func(args map[string]object.Object) object.Object {
+			result, ok := args["result"].(*object.Struct)
+			if !ok {
+				return argError("result", "search result", args["result"])
+			}
+			dist, ok1 := result.Fields["dist"].(*object.Map)
+			prev, ok2 := result.Fields["prev"].(*object.Map)
+			if !ok1 || !ok2 {
+				return argError("result", "search result", args["result"])
+			}
+			return returnResult(shortestPath(dist, prev, args["node"]))
+		},
+	},
+	"toposort": wholeGraphFunc(false, func(g *graph, nodes []object.Object) object.Object {
+		// Kahn's algorithm, nodes which become free at the same time keep the order they were listed in
+		indegree := map[string]int{}
+		for _, node := range nodes {
+			edges, err := g.neighbors(node)
+			if err != nil {
+				return err
+			}
+			for _, e := range edges {
+				hash, _ := nodeHash(e.node)
+				indegree[hash]++
+			}
+		}
+		var ready []object.Object
+		for _, node := range nodes {
+			if hash, _ := nodeHash(node); indegree[hash] == 0 {
+				ready = append(ready, node)
+			}
+		}
+		ret := &object.Array{}
+		for len(ready) > 0 {
+			node := ready[0]
+			ready = ready[1:]
+			ret.Items = append(ret.Items, node)
+			edges, _ := g.neighbors(node)
+			for _, e := range edges {
+				hash, _ := nodeHash(e.node)
+				indegree[hash]--
+				if indegree[hash] == 0 {
+					ready = append(ready, e.node)
+				}
+			}
+		}
+		if len(ret.Items) != len(nodes) {
+			return &object.Error{Msg: "the graph has a cycle"}
+		}
+		return ret
+	}),
+	"components": wholeGraphFunc(false, func(g *graph, nodes []object.Object) object.Object {
+		// edges are followed in both directions
+		undirected := map[string][]object.Object{}
+		for _, node := range nodes {
+			hash, _ := nodeHash(node)
+			edges, _ := g.neighbors(node)
+			for _, e := range edges {
+				nextHash, _ := nodeHash(e.node)
+				undirected[hash] = append(undirected[hash], e.node)
+				undirected[nextHash] = append(undirected[nextHash], node)
+			}
+		}
+		seen := map[string]bool{}
+		ret := &object.Array{}
+		for _, node := range nodes {
+			if hash, _ := nodeHash(node); seen[hash] {
+				continue
+			}
+			component := &object.Array{}
+			next := []object.Object{node}
+			for len(next) > 0 {
+				node := next[0]
+				next = next[1:]
+				hash, _ := nodeHash(node)
+				if seen[hash] {
+					continue
+				}
+				seen[hash] = true
+				component.Items = append(component.Items, node)
+				next = append(next, undirected[hash]...)
+			}
+			ret.Items = append(ret.Items, component)
+		}
+		return ret
+	}),
+	"floyd_warshall": wholeGraphFunc(true, func(g *graph, nodes []object.Object) object.Object {
+		n := len(nodes)
+		index := map[string]int{}
+		for i, node := range nodes {
+			hash, _ := nodeHash(node)
+			index[hash] = i
+		}
+		dist := make([][]int, n)
+		reachable := make([][]bool, n)
+		for i := range dist {
+			dist[i] = make([]int, n)
+			reachable[i] = make([]bool, n)
+			reachable[i][i] = true
+		}
+		for i, node := range nodes {
+			edges, _ := g.neighbors(node)
+			for _, e := range edges {
+				hash, _ := nodeHash(e.node)
+				if j := index[hash]; !reachable[i][j] || e.cost < dist[i][j] {
+					dist[i][j] = e.cost
+					reachable[i][j] = true
+				}
+			}
+		}
+		for k := 0; k < n; k++ {
+			for i := 0; i < n; i++ {
+				if !reachable[i][k] {
+					continue
+				}
+				for j := 0; j < n; j++ {
+					if !reachable[k][j] {
+						continue
+					}
+					if d := dist[i][k] + dist[k][j]; !reachable[i][j] || d < dist[i][j] {
+						dist[i][j] = d
+						reachable[i][j] = true
+					}
+				}
+			}
+		}
+
+		ret := newMap()
+		for i, from := range nodes {
+			if dist[i][i] < 0 {
+				return &object.Error{Msg: "the graph has a negative cycle through " + from.String()}
+			}
+			row := newMap()
+			for j, to := range nodes {
+				if reachable[i][j] {
+					hash, _ := nodeHash(to)
+					row.Fields[hash] = object.MapItem{Key: to, Value: &object.Number{Value: dist[i][j]}}
+				}
+			}
+			hash, _ := nodeHash(from)
+			ret.Fields[hash] = object.MapItem{Key: from, Value: row}
+		}
+		return ret
+	}),
+}
@@ -0,0 +1,334 @@
+package funcs
+
+import (
+	"fmt"
+	"ryanlang/object"
+	"strings"
+)
+
+func gridArg(args map[string]object.Object, name string) (*object.Grid, object.Object) {
+	g, ok := args[name].(*object.Grid)
+	if !ok {
+		return nil, argError(name, "grid", args[name])
+	}
+	return g, nil
+}
+
+// pointArg accepts coordinates of a cell of the grid as an array or a tuple of numbers, e.g. [x, y]
+func pointArg(args map[string]object.Object, name string, g *object.Grid) ([]int, object.Object) {
+	var items []object.Object
+	switch p := args[name].(type) {
+	case *object.Array:
+		items = p.Items
+	case *object.Tuple:
+		items = p.Values
+	}
+	if len(items) != len(g.Size) {
+		return nil, argError(name, fmt.Sprintf("point of %d numbers", len(g.Size)), args[name])
+	}
+	ret := make([]int, len(items))
+	for i, item := range items {
+		n, ok := item.(*object.Number)
+		if !ok {
+			return nil, argError(name, fmt.Sprintf("point of %d numbers", len(g.Size)), args[name])
+		}
+		ret[i] = n.Value
+	}
+	return ret, nil
+}
+
+func pointObject(p []int) *object.Array {
+	ret := &object.Array{Items: make([]object.Object, len(p))}
+	for i, n := range p {
+		ret.Items[i] = &object.Number{Value: n}
+	}
+	return ret
+}
+
+// maxGridCells limits the size of the grids, larger ones would take gigabytes or overflow the number of cells
+const maxGridCells = 1 << 27
+
+func newGrid(size []int, v object.Object) object.Object {
+	cells := 1
+	for _, n := range size {
+		if n < 0 {
+			return &object.Error{Msg: fmt.Sprintf("negative grid size: %d", n)}
+		}
+		if n == 0 {
+			cells = 0
+		}
+	}
+	for _, n := range size {
+		if cells == 0 {
+			break
+		}
+		if n > maxGridCells/cells {
+			return &object.Error{Msg: fmt.Sprintf("grid size %v has more than %d cells", size, maxGridCells)}
+		}
+		cells *= n
+	}
+	g := &object.Grid{Size: size, Cells: make([]object.Object, cells)}
+	for i := range g.Cells {
+		g.Cells[i] = v
+	}
+	return g
+}
+
+// neighbors returns the points around p which are inside the grid, in the reading order. If diagonal is false,
+// only the points differing from p in a single coordinate are returned.
+func neighbors(g *object.Grid, p []int, diagonal bool) *object.Array {
+	ret := &object.Array{}
+	delta := make([]int, len(p))
+	var walk func(d int, changed int)
+	walk = func(d int, changed int) {
+		if d < 0 {
+			if changed == 0 || (!diagonal && changed > 1) {
+				return
+			}
+			q := make([]int, len(p))
+			for i := range p {
+				q[i] = p[i] + delta[i]
+			}
+			if _, ok := g.Index(q); ok {
+				ret.Items = append(ret.Items, pointObject(q))
+			}
+			return
+		}
+		// the last coordinate is the outermost one, so that the points go row by row
+		for _, delta[d] = range []int{-1, 0, 1} {
+			if delta[d] != 0 {
+				walk(d-1, changed+1)
+			} else {
+				walk(d-1, changed)
+			}
+		}
+		delta[d] = 0
+	}
+	walk(len(p)-1, 0)
+	return ret
+}
+
+// gridLines returns the cells of a two-dimensional grid by rows or by columns
+func gridLines(g *object.Grid, byColumn bool) object.Object {
+	if len(g.Size) != 2 {
+		return &object.Error{Msg: "two-dimensional grid expected"}
+	}
+	outer, inner := g.Size[1], g.Size[0]
+	if byColumn {
+		outer, inner = inner, outer
+	}
+	ret := &object.Array{Items: make([]object.Object, outer)}
+	for i := range ret.Items {
+		line := &object.Array{Items: make([]object.Object, inner)}
+		for j := range line.Items {
+			p := []int{j, i}
+			if byColumn {
+				p = []int{i, j}
+			}
+			k, _ := g.Index(p)
+			line.Items[j] = g.Cells[k]
+		}
+		ret.Items[i] = line
+	}
+	return ret
+}
+
+// formatGrid renders the grid one row per line, string cells are printed as is, layers of a three-dimensional grid
+// are separated by an empty line
+func formatGrid(g *object.Grid) string {
+	var sb strings.Builder
+	for i, cell := range g.Cells {
+		if i > 0 {
+			switch {
+			case len(g.Size) > 2 && i%(g.Size[0]*g.Size[1]) == 0:
+				sb.WriteString("\n\n")
+			case i%g.Size[0] == 0:
+				sb.WriteString("\n")
+			}
+		}
+		if s, ok := cell.(*object.String); ok {
+			sb.WriteString(s.Value)
+		} else {
+			sb.WriteString(cell.String())
+		}
+	}
+	return sb.String()
+}
+
+func gridFunc(f func(g *object.Grid) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"g"},
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := gridArg(args, "g")
+			if err != nil {
+				return err
+			}
+			return returnResult(f(g))
+		},
+	}
+}
+
+// gridPointFunc makes a built-in function of a grid and a point
+func gridPointFunc(f func(g *object.Grid, p []int) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"g", "p"},
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := gridArg(args, "g")
+			if err != nil {
+				return err
+			}
+			p, err := pointArg(args, "p", g)
+			if err != nil {
+				return err
+			}
+			return returnResult(f(g, p))
+		},
+	}
+}
+
+var gridModule = map[string]BuiltinFunction{
+	"new": {
+		Arguments: []string{"w", "h", "v"},
+		Body: func(args map[string]object.Object) object.Object {
+			w, err := numberArg(args, "w")
+			if err != nil {
+				return err
+			}
+			h, err := numberArg(args, "h")
+			if err != nil {
+				return err
+			}
+			return returnResult(newGrid([]int{w, h}, args["v"]))
+		},
+	},
+	"new3d": {
+		Arguments: []string{"w", "h", "d", "v"},
+		Body: func(args map[string]object.Object) object.Object {
+			w, err := numberArg(args, "w")
+			if err != nil {
+				return err
+			}
+			h, err := numberArg(args, "h")
+			if err != nil {
+				return err
+			}
+			d, err := numberArg(args, "d")
+			if err != nil {
+				return err
+			}
+			return returnResult(newGrid([]int{w, h, d}, args["v"]))
+		},
+	},
+	"parse": {
+		Arguments: []string{"lines"},
+		Body: func(args map[string]object.Object) object.Object {
+			items, err := arrayArg(args, "lines")
+			if err != nil {
+				return err
+			}
+			rows := make([][]rune, len(items))
+			w := 0
+			for i, item := range items {
+				s, ok := item.(*object.String)
+				if !ok {
+					return argError("lines", "array of strings", item)
+				}
+				rows[i] = []rune(s.Value)
+				if len(rows[i]) > w {
+					w = len(rows[i])
+				}
+			}
+			// shorter lines are padded with spaces
+			g := newGrid([]int{w, len(rows)}, &object.String{Value: " "}).(*object.Grid)
+			for y, row := range rows {
+				for x, r := range row {
+					g.Cells[y*w+x] = &object.String{Value: string(r)}
+				}
+			}
+			return returnValue(g)
+		},
+	},
+	"size": gridFunc(func(g *object.Grid) object.Object {
+		return pointObject(g.Size)
+	}),
+	"get": gridPointFunc(func(g *object.Grid, p []int) object.Object {
+		i, ok := g.Index(p)
+		if !ok {
+			return &object.Error{Msg: "point is out of the grid: " + pointObject(p).String()}
+		}
+		return g.Cells[i]
+	}),
+	"set": {
+		Arguments: []string{"g", "p", "v"},
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := gridArg(args, "g")
+			if err != nil {
+				return err
+			}
+			p, err := pointArg(args, "p", g)
+			if err != nil {
+				return err
+			}
+			i, ok := g.Index(p)
+			if !ok {
+				return &object.Error{Msg: "point is out of the grid: " + pointObject(p).String()}
+			}
+			g.Cells[i] = args["v"]
+			return returnValue(&object.StaticNull)
+		},
+	},
+	"inbounds": gridPointFunc(func(g *object.Grid, p []int) object.Object {
+		_, ok := g.Index(p)
+		return object.StaticBool(ok)
+	}),
+	"neighbors4": gridPointFunc(func(g *object.Grid, p []int) object.Object {
+		return neighbors(g, p, false)
+	}),
+	"neighbors8": gridPointFunc(func(g *object.Grid, p []int) object.Object {
+		return neighbors(g, p, true)
+	}),
+	"rows": gridFunc(func(g *object.Grid) object.Object {
+		return gridLines(g, false)
+	}),
+	"columns": gridFunc(func(g *object.Grid) object.Object {
+		return gridLines(g, true)
+	}),
+	"find": {
+		Arguments: []string{"g", "v"},
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := gridArg(args, "g")
+			if err != nil {
+				return err
+			}
+			for i, cell := range g.Cells {
+				eq := EqTest(cell, args["v"])
+				if object.IsError(eq) {
+					return eq
+				}
+				if eq.(*object.Boolean).Value {
+					return returnValue(pointObject(g.Point(i)))
+				}
+			}
+			return returnValue(&object.StaticNull)
+		},
+	},
+	"copy": gridFunc(func(g *object.Grid) object.Object {
+		ret := &object.Grid{Size: g.Size, Cells: make([]object.Object, len(g.Cells))}
+		copy(ret.Cells, g.Cells)
+		return ret
+	}),
+	"format": gridFunc(func(g *object.Grid) object.Object {
+		return &object.String{Value: formatGrid(g)}
+	}),
+	"print": {
+		Arguments: []string{"g"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			g, err := gridArg(args, "g")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(ctx.Stdout(), formatGrid(g))
+			return returnValue(&object.StaticNull)
+		},
+	},
+}
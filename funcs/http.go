@@ -0,0 +1,177 @@
+package funcs
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"ryanlang/object"
+	"strings"
+)
+
+// httpExchange is a request waiting to be handled by the program and the channel its response is sent to
+type httpExchange struct {
+	request  object.Object
+	response chan httpResponse
+}
+
+type httpResponse struct {
+	status  int
+	headers map[string]string
+	body    string
+}
+
+// HTTPHandler exposes a ryanlang function as an http.Handler. The engines are not safe for concurrent use, so
+// ServeHTTP, which runs on the goroutines of the server, only queues the requests: they are passed to the function
+// one at a time by Run, on the goroutine running the program.
+type HTTPHandler struct {
+	ctx      Context
+	fn       object.Object
+	requests chan httpExchange
+}
+
+func NewHTTPHandler(ctx Context, fn object.Object) *HTTPHandler {
+	return &HTTPHandler{ctx: ctx, fn: fn, requests: make(chan httpExchange)}
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exchange := httpExchange{request: requestObject(r, string(body)), response: make(chan httpResponse, 1)}
+	select {
+	case h.requests <- exchange:
+	case <-r.Context().Done():
+		return
+	}
+	resp := <-exchange.response
+	for name, value := range resp.headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(resp.status)
+	io.WriteString(w, resp.body)
+}
+
+// Run handles the queued requests until stop is closed
+func (h *HTTPHandler) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case exchange := <-h.requests:
+			exchange.response <- h.handle(exchange.request)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handle calls the function, errors are reported on stderr and answered with 500 without stopping the server
+func (h *HTTPHandler) handle(request object.Object) httpResponse {
+	ret := h.ctx.Call(h.fn, request)
+	if !object.IsError(ret) {
+		resp, err := responseValue(ret)
+		if err == nil {
+			return resp
+		}
+		ret = err
+	}
+	// the innermost error is the cause, the outer ones only wrap it
+	cause := ret.(*object.Error).Last(1)[0]
+	if cause.Loc != nil {
+		fmt.Fprintf(h.ctx.Stderr(), "http handler: %s: %s\n", cause.Loc.String(), cause.Msg)
+	} else {
+		fmt.Fprintf(h.ctx.Stderr(), "http handler: %s\n", cause.Msg)
+	}
+	return httpResponse{status: http.StatusInternalServerError, body: "internal server error\n"}
+}
+
+// requestObject is what the handler receives: struct{method; path; query; headers; body}, headers with several
+// values are joined with commas
+func requestObject(r *http.Request, body string) object.Object {
+	headers := newMap()
+	for name, values := range r.Header {
+		key := &object.String{Value: name}
+		headers.Fields[key.Hash()] = object.MapItem{Key: key, Value: &object.String{Value: strings.Join(values, ", ")}}
+	}
+	return &object.Struct{Fields: map[string]object.Object{
+		"method":  &object.String{Value: r.Method},
+		"path":    &object.String{Value: r.URL.Path},
+		"query":   &object.String{Value: r.URL.RawQuery},
+		"headers": headers,
+		"body":    &object.String{Value: body},
+	}}
+}
+
+// responseValue accepts a string, which is sent with status 200, or struct{status; headers; body} where every field
+// is optional
+func responseValue(ret object.Object) (httpResponse, object.Object) {
+	resp := httpResponse{status: http.StatusOK}
+	switch ret := ret.(type) {
+	case *object.String:
+		resp.body = ret.Value
+		return resp, nil
+	case *object.Struct:
+		if status, ok := ret.Fields["status"]; ok {
+			n, isNumber := status.(*object.Number)
+			if !isNumber || n.Value < 100 || n.Value > 999 {
+				return resp, &object.Error{Msg: "response status must be a number from 100 to 999, got: " + status.String()}
+			}
+			resp.status = n.Value
+		}
+		if body, ok := ret.Fields["body"]; ok {
+			s, isString := body.(*object.String)
+			if !isString {
+				return resp, &object.Error{Msg: "response body must be a string, got: " + body.Type().String()}
+			}
+			resp.body = s.Value
+		}
+		if headers, ok := ret.Fields["headers"]; ok {
+			m, isMap := headers.(*object.Map)
+			if !isMap {
+				return resp, &object.Error{Msg: "response headers must be a map, got: " + headers.Type().String()}
+			}
+			resp.headers = map[string]string{}
+			for _, item := range m.Fields {
+				name, isString := item.Key.(*object.String)
+				value, isStringValue := item.Value.(*object.String)
+				if !isString || !isStringValue {
+					return resp, &object.Error{Msg: "response headers must map strings to strings"}
+				}
+				resp.headers[name.Value] = value.Value
+			}
+		}
+		return resp, nil
+	}
+	return resp, &object.Error{Msg: "handler must return a string or a struct, got: " + ret.Type().String()}
+}
+
+var httpModule = map[string]BuiltinFunction{
+	"serve": {
+		Arguments: []string{"addr", "handler"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			addr, err := stringArg(args, "addr")
+			if err != nil {
+				return err
+			}
+			fn, err := funcArg(args, "handler", false)
+			if err != nil {
+				return err
+			}
+			ln, listenErr := net.Listen("tcp", addr)
+			if listenErr != nil {
+				return &object.Error{Msg: listenErr.Error()}
+			}
+			h := NewHTTPHandler(ctx, fn)
+			stop := make(chan struct{})
+			var serveErr error
+			go func() {
+				serveErr = http.Serve(ln, h)
+				close(stop)
+			}()
+			// serving only ends when the listener fails
+			h.Run(stop)
+			return &object.Error{Msg: serveErr.Error()}
+		},
+	},
+}
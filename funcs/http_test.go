@@ -0,0 +1,108 @@
+package funcs_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ryanlang/compiler"
+	"ryanlang/eval"
+	"ryanlang/funcs"
+	"ryanlang/lexer"
+	"ryanlang/object"
+	"ryanlang/parser"
+	"ryanlang/vm"
+	"strings"
+	"testing"
+)
+
+const handlerSource = `
+exports { handler; };
+let count = 0;
+let handler = func(req) {
+    count++;
+    if req.path == "/fail" { return [].(1); };
+    if req.path == "/plain" { return format("plain {}", count); };
+    return struct{
+        status: 201;
+        headers: map{"X-Count": format("{}", count);};
+        body: format("{} {} {} {} {}", req.method, req.path, req.query, req.headers.("X-Test"), req.body);
+    };
+};
+`
+
+// handlerFunc runs the program in one of the engines and returns the engine and the exported handler
+func handlerFunc(t *testing.T, engine string) (funcs.Context, object.Object) {
+	mod := parser.New(lexer.NewFromString(handlerSource)).ReadModule("test")
+	if engine == "eval" {
+		e := eval.New()
+		e.SetOutput(io.Discard, io.Discard)
+		ret := e.Eval(mod)
+		if object.IsError(ret) {
+			t.Fatal(ret.String())
+		}
+		return e, ret.(*object.Module).Exports["handler"]
+	}
+	compiled, err := compiler.NewCompiler().CompileRunModule(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := vm.New(compiled)
+	v.DisableDebugger()
+	v.SetOutput(io.Discard, io.Discard)
+	ret := v.Run()
+	if object.IsError(ret) {
+		t.Fatal(ret.String())
+	}
+	return v, ret.(*object.Struct).Fields["handler"]
+}
+
+func TestHTTPHandler(t *testing.T) {
+	tc := []struct {
+		method, path, body string
+		status             int
+		header             string
+		want               string
+	}{
+		{method: "POST", path: "/a/b?x=1", body: "hello", status: 201, header: "1", want: "POST /a/b x=1 yes hello"},
+		{method: "GET", path: "/plain", status: 200, want: "plain 2"},
+		{method: "GET", path: "/fail", status: 500, want: "internal server error\n"},
+		{method: "GET", path: "/plain", status: 200, want: "plain 4"},
+	}
+
+	for _, engine := range []string{"eval", "vm"} {
+		t.Run(engine, func(t *testing.T) {
+			ctx, fn := handlerFunc(t, engine)
+			h := funcs.NewHTTPHandler(ctx, fn)
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+
+			// the requests are made from another goroutine, the handler runs on this one like it does in http.serve
+			stop := make(chan struct{})
+			go func() {
+				defer close(stop)
+				for _, tt := range tc {
+					req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					req.Header.Set("X-Test", "yes")
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					body, _ := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode != tt.status || string(body) != tt.want {
+						t.Errorf("%s %s: want=%d %q, got=%d %q", tt.method, tt.path, tt.status, tt.want, resp.StatusCode, body)
+					}
+					if got := resp.Header.Get("X-Count"); got != tt.header {
+						t.Errorf("%s %s: want X-Count=%q, got=%q", tt.method, tt.path, tt.header, got)
+					}
+				}
+			}()
+			h.Run(stop)
+		})
+	}
+}
@@ -0,0 +1,546 @@
+package funcs
+
+import (
+	"ryanlang/object"
+	"sort"
+	"unicode/utf8"
+)
+
+// sortedMapItems returns the entries of a map ordered by key, so that iterating over a map is deterministic
+func sortedMapItems(m *object.Map) []object.MapItem {
+	hashes := make([]string, 0, len(m.Fields))
+	for hash := range m.Fields {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		a, b := m.Fields[hashes[i]].Key, m.Fields[hashes[j]].Key
+		if c, err := compare(a, b); err == nil {
+			return c < 0
+		}
+		// keys which are not comparable, e.g. of different types
+		if a.Type() != b.Type() {
+			return a.Type() < b.Type()
+		}
+		return hashes[i] < hashes[j]
+	})
+	items := make([]object.MapItem, len(hashes))
+	for i, hash := range hashes {
+		items[i] = m.Fields[hash]
+	}
+	return items
+}
+
+// iterate calls f for every item of a collection: items of an array, a tuple, a heap or a deque, cells of a grid,
+// [key, value] pairs of a map ordered by key, characters of a string, numbers of a range or values of an iterator. It stops early if f returns false or an error.
+func iterate(c object.Object, f func(item object.Object) (bool, object.Object)) object.Object {
+	var items []object.Object
+	switch c := c.(type) {
+	case *object.Array:
+		items = c.Items
+	case *object.Tuple:
+		items = c.Values
+	case *object.Heap:
+		items = c.Values()
+	case *object.Deque:
+		items = c.Values()
+	case *object.Grid:
+		items = c.Cells
+	case *object.Map:
+		for _, item := range sortedMapItems(c) {
+			if more, err := f(&object.Array{Items: []object.Object{item.Key, item.Value}}); err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	case *object.String:
+		for _, r := range c.Value {
+			if more, err := f(&object.String{Value: string(r)}); err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	case *object.Range:
+		// ranges are never materialized
+		for i := 0; i < c.Len(); i++ {
+			if more, err := f(&object.Number{Value: c.At(i)}); err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	case *object.Iterator:
+		for {
+			_, value := c.Next()
+			if value == nil {
+				return nil
+			}
+			if object.IsError(value) {
+				return value
+			}
+			if more, err := f(value); err != nil || !more {
+				return err
+			}
+		}
+	default:
+		return argError("c", "array, map, string, range, heap, deque or grid", c)
+	}
+	for _, item := range items {
+		if more, err := f(item); err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// Iterator returns the index, value pairs a for loop goes over: positions and items of an array, a heap or a deque,
+// keys and values of a map, points and cells of a grid, positions and characters of a string or positions and numbers
+// of a range. Nothing is copied except the entries of a map, ranges in particular are iterated by index.
+func Iterator(c object.Object) object.Object {
+	var items []object.Object
+	switch c := c.(type) {
+	case *object.Iterator:
+		return c
+	case *object.Array:
+		items = c.Items
+	case *object.Heap:
+		items = c.Values()
+	case *object.Deque:
+		items = c.Values()
+	case *object.Map:
+		entries := make([]object.MapItem, 0, len(c.Fields))
+		for _, item := range c.Fields {
+			entries = append(entries, item)
+		}
+		i := 0
+		return &object.Iterator{Next: func() (object.Object, object.Object) {
+			if i >= len(entries) {
+				return nil, nil
+			}
+			i++
+			return entries[i-1].Key, entries[i-1].Value
+		}}
+	case *object.Grid:
+		i := 0
+		return &object.Iterator{Next: func() (object.Object, object.Object) {
+			if i >= len(c.Cells) {
+				return nil, nil
+			}
+			i++
+			return pointObject(c.Point(i - 1)), c.Cells[i-1]
+		}}
+	case *object.String:
+		i, offset := 0, 0
+		return &object.Iterator{Next: func() (object.Object, object.Object) {
+			if offset >= len(c.Value) {
+				return nil, nil
+			}
+			r, size := utf8.DecodeRuneInString(c.Value[offset:])
+			i, offset = i+1, offset+size
+			return &object.Number{Value: i - 1}, &object.String{Value: string(r)}
+		}}
+	case *object.Range:
+		i := 0
+		return &object.Iterator{Next: func() (object.Object, object.Object) {
+			if i >= c.Len() {
+				return nil, nil
+			}
+			i++
+			return &object.Number{Value: i - 1}, &object.Number{Value: c.At(i - 1)}
+		}}
+	default:
+		return &object.Error{Msg: "cannot iterate over type " + c.Type().String()}
+	}
+	i := 0
+	return &object.Iterator{Next: func() (object.Object, object.Object) {
+		if i >= len(items) {
+			return nil, nil
+		}
+		i++
+		return &object.Number{Value: i - 1}, items[i-1]
+	}}
+}
+
+func collectionItems(c object.Object) ([]object.Object, object.Object) {
+	var ret []object.Object
+	err := iterate(c, func(item object.Object) (bool, object.Object) {
+		ret = append(ret, item)
+		return true, nil
+	})
+	return ret, err
+}
+
+// call calls a function and separates its result from an error
+func call(ctx Context, fn object.Object, args ...object.Object) (object.Object, object.Object) {
+	ret := ctx.Call(fn, args...)
+	if object.IsError(ret) {
+		return nil, ret
+	}
+	return ret, nil
+}
+
+// test calls a predicate, which must return a bool. Without a predicate the item itself must be a bool
+func test(ctx Context, fn object.Object, item object.Object) (bool, object.Object) {
+	ret := item
+	if fn != nil {
+		var err object.Object
+		if ret, err = call(ctx, fn, item); err != nil {
+			return false, err
+		}
+	}
+	b, ok := ret.(*object.Boolean)
+	if !ok {
+		if fn == nil {
+			return false, &object.Error{Msg: "bool items expected without a predicate, got: " + ret.Type().String()}
+		}
+		return false, &object.Error{Msg: "predicate must return a bool, got: " + ret.Type().String()}
+	}
+	return b.Value, nil
+}
+
+// sizeArg accepts a positive number, e.g. a chunk size
+func sizeArg(args map[string]object.Object, name string) (int, object.Object) {
+	n, err := numberArg(args, name)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, &object.Error{Msg: "argument " + name + ": must be positive"}
+	}
+	return n, nil
+}
+
+// quantifier makes any or all: the result is decided by the first item for which the predicate returns stopAt
+func quantifier(stopAt bool) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"c", "f"},
+		Optional:  1,
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			f, err := funcArg(args, "f", true)
+			if err != nil {
+				return err
+			}
+			decided := false
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				ok, err := test(ctx, f, item)
+				if err != nil {
+					return false, err
+				}
+				decided = ok == stopAt
+				return !decided, nil
+			}); err != nil {
+				return err
+			}
+			// any is true and all is false once decided, otherwise it's the other way around
+			return returnValue(object.StaticBool(decided == stopAt))
+		},
+	}
+}
+
+var iterModule = map[string]BuiltinFunction{
+	"range": {
+		Arguments: []string{"from", "to", "step"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			from, err := numberArg(args, "from")
+			if err != nil {
+				return err
+			}
+			to, err := numberArg(args, "to")
+			if err != nil {
+				return err
+			}
+			step := 1
+			if args["step"].Type() != object.NULL {
+				if step, err = numberArg(args, "step"); err != nil {
+					return err
+				}
+				if step == 0 {
+					return &object.Error{Msg: "argument step: must not be zero"}
+				}
+			}
+			r := &object.Range{From: from, To: to, Step: step}
+			if !r.Fits() {
+				return &object.Error{Msg: "too many items in " + r.String()}
+			}
+			return returnValue(r)
+		},
+	},
+	"map": {
+		Arguments: []string{"c", "f"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			f, err := funcArg(args, "f", false)
+			if err != nil {
+				return err
+			}
+			ret := &object.Array{Items: []object.Object{}}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				v, err := call(ctx, f, item)
+				ret.Items = append(ret.Items, v)
+				return true, err
+			}); err != nil {
+				return err
+			}
+			return returnValue(ret)
+		},
+	},
+	"filter": {
+		Arguments: []string{"c", "f"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			f, err := funcArg(args, "f", false)
+			if err != nil {
+				return err
+			}
+			ret := &object.Array{Items: []object.Object{}}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				ok, err := test(ctx, f, item)
+				if ok {
+					ret.Items = append(ret.Items, item)
+				}
+				return true, err
+			}); err != nil {
+				return err
+			}
+			return returnValue(ret)
+		},
+	},
+	"reduce": {
+		Arguments: []string{"c", "f", "init"},
+		Optional:  1,
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			f, err := funcArg(args, "f", false)
+			if err != nil {
+				return err
+			}
+			var acc object.Object
+			if args["init"].Type() != object.NULL {
+				acc = args["init"]
+			}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				if acc == nil { // without init the first item is used
+					acc = item
+					return true, nil
+				}
+				var err object.Object
+				acc, err = call(ctx, f, acc, item)
+				return true, err
+			}); err != nil {
+				return err
+			}
+			if acc == nil {
+				return &object.Error{Msg: "reduce of an empty collection without an initial value"}
+			}
+			return returnValue(acc)
+		},
+	},
+	"any": quantifier(true),
+	"all": quantifier(false),
+	"count": {
+		Arguments: []string{"c", "f"},
+		Optional:  1,
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			f, err := funcArg(args, "f", true)
+			if err != nil {
+				return err
+			}
+			if r, ok := args["c"].(*object.Range); ok && f == nil {
+				return returnValue(&object.Number{Value: r.Len()})
+			}
+			n := 0
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				if f == nil {
+					n++
+					return true, nil
+				}
+				ok, err := test(ctx, f, item)
+				if ok {
+					n++
+				}
+				return true, err
+			}); err != nil {
+				return err
+			}
+			return returnValue(&object.Number{Value: n})
+		},
+	},
+	"sum": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			sum := 0
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				n, ok := item.(*object.Number)
+				if !ok {
+					return false, &object.Error{Msg: "cannot sum items of type " + item.Type().String()}
+				}
+				sum += n.Value
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			return returnValue(&object.Number{Value: sum})
+		},
+	},
+	"zip": {
+		Arguments: []string{"a", "b"},
+		Body: func(args map[string]object.Object) object.Object {
+			a, err := collectionItems(args["a"])
+			if err != nil {
+				return err
+			}
+			b, err := collectionItems(args["b"])
+			if err != nil {
+				return err
+			}
+			ret := &object.Array{Items: []object.Object{}}
+			for i := 0; i < len(a) && i < len(b); i++ {
+				ret.Items = append(ret.Items, &object.Array{Items: []object.Object{a[i], b[i]}})
+			}
+			return returnValue(ret)
+		},
+	},
+	"enumerate": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			ret := &object.Array{Items: []object.Object{}}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				ret.Items = append(ret.Items, &object.Array{Items: []object.Object{&object.Number{Value: len(ret.Items)}, item}})
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			return returnValue(ret)
+		},
+	},
+	"flatten": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			// only a single level of nesting is removed
+			ret := &object.Array{Items: []object.Object{}}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				switch item := item.(type) {
+				case *object.Array:
+					ret.Items = append(ret.Items, item.Items...)
+				case *object.Tuple:
+					ret.Items = append(ret.Items, item.Values...)
+				default:
+					ret.Items = append(ret.Items, item)
+				}
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			return returnValue(ret)
+		},
+	},
+	"chunk": {
+		Arguments: []string{"c", "n"},
+		Body: func(args map[string]object.Object) object.Object {
+			n, err := sizeArg(args, "n")
+			if err != nil {
+				return err
+			}
+			items, err := collectionItems(args["c"])
+			if err != nil {
+				return err
+			}
+			// the last chunk is shorter if the items don't divide evenly
+			ret := &object.Array{Items: []object.Object{}}
+			for i := 0; i < len(items); i += n {
+				end := i + n
+				if end > len(items) {
+					end = len(items)
+				}
+				ret.Items = append(ret.Items, &object.Array{Items: append([]object.Object{}, items[i:end]...)})
+			}
+			return returnValue(ret)
+		},
+	},
+	"window": {
+		Arguments: []string{"c", "n"},
+		Body: func(args map[string]object.Object) object.Object {
+			n, err := sizeArg(args, "n")
+			if err != nil {
+				return err
+			}
+			items, err := collectionItems(args["c"])
+			if err != nil {
+				return err
+			}
+			ret := &object.Array{Items: []object.Object{}}
+			for i := 0; i+n <= len(items); i++ {
+				ret.Items = append(ret.Items, &object.Array{Items: append([]object.Object{}, items[i:i+n]...)})
+			}
+			return returnValue(ret)
+		},
+	},
+	"groupby": {
+		Arguments: []string{"c", "f"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			f, err := funcArg(args, "f", false)
+			if err != nil {
+				return err
+			}
+			ret := &object.Map{Fields: map[string]object.MapItem{}}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				key, err := call(ctx, f, item)
+				if err != nil {
+					return false, err
+				}
+				if key = expectHashable(key); object.IsError(key) {
+					return false, key
+				}
+				hash := key.(object.Hashable).Hash()
+				group, ok := ret.Fields[hash]
+				if !ok {
+					group = object.MapItem{Key: key, Value: &object.Array{}}
+					ret.Fields[hash] = group
+				}
+				group.Value.(*object.Array).Items = append(group.Value.(*object.Array).Items, item)
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			return returnValue(ret)
+		},
+	},
+	"uniq": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			// the first occurrence of every item is kept
+			seen := map[string]bool{}
+			ret := &object.Array{Items: []object.Object{}}
+			if err := iterate(args["c"], func(item object.Object) (bool, object.Object) {
+				if hashable, ok := item.(object.Hashable); ok {
+					if !seen[hashable.Hash()] {
+						seen[hashable.Hash()] = true
+						ret.Items = append(ret.Items, item)
+					}
+					return true, nil
+				}
+				found := In(item, ret)
+				if object.IsError(found) {
+					return false, found
+				}
+				if !found.(*object.Boolean).Value {
+					ret.Items = append(ret.Items, item)
+				}
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			return returnValue(ret)
+		},
+	},
+	"reverse": {
+		Arguments: []string{"c"},
+		Body: func(args map[string]object.Object) object.Object {
+			items, err := collectionItems(args["c"])
+			if err != nil {
+				return err
+			}
+			ret := &object.Array{Items: make([]object.Object, len(items))}
+			for i, item := range items {
+				ret.Items[len(items)-1-i] = item
+			}
+			return returnValue(ret)
+		},
+	},
+}
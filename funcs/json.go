@@ -0,0 +1,220 @@
+package funcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"ryanlang/object"
+	"strconv"
+	"strings"
+)
+
+// maxJSONDepth limits nesting of the stringified values, arrays and maps containing themselves would recurse forever otherwise
+const maxJSONDepth = 1000
+
+// jsonOffsetError adds the byte offset of the input to decoding errors
+func jsonOffsetError(d *json.Decoder, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("at offset %d: %s", syntaxErr.Offset, syntaxErr.Error())
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("at offset %d: %s", d.InputOffset(), err.Error())
+}
+
+// jsonInteger parses a json number, floats are accepted as long as their value is an integer, e.g. 1.0 or 1e3
+func jsonInteger(s string) (int, bool) {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i, true
+	}
+	// float64 rules out fractions and numbers out of range cheaply, even with exponents like 1e1000000, but it rounds,
+	// so the exact value is checked with a rational
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt {
+		return 0, false
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	i := r.Num().Int64()
+	return int(i), int64(int(i)) == i
+}
+
+func decodeJSON(d *json.Decoder) (object.Object, error) {
+	offset := d.InputOffset()
+	tok, err := d.Token()
+	if err != nil {
+		return nil, jsonOffsetError(d, err)
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '[':
+			ret := &object.Array{Items: []object.Object{}}
+			for d.More() {
+				item, err := decodeJSON(d)
+				if err != nil {
+					return nil, err
+				}
+				ret.Items = append(ret.Items, item)
+			}
+			if _, err := d.Token(); err != nil { // closing ]
+				return nil, jsonOffsetError(d, err)
+			}
+			return ret, nil
+		case '{':
+			ret := &object.Map{Fields: map[string]object.MapItem{}}
+			for d.More() {
+				keyTok, err := d.Token()
+				if err != nil {
+					return nil, jsonOffsetError(d, err)
+				}
+				key := &object.String{Value: keyTok.(string)}
+				value, err := decodeJSON(d)
+				if err != nil {
+					return nil, err
+				}
+				ret.Fields[key.Hash()] = object.MapItem{Key: key, Value: value}
+			}
+			if _, err := d.Token(); err != nil { // closing }
+				return nil, jsonOffsetError(d, err)
+			}
+			return ret, nil
+		}
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		if i, ok := jsonInteger(tok.String()); ok {
+			return &object.Number{Value: i}, nil
+		}
+		// the decoder is past the number now, and its text is exactly the literal
+		start := d.InputOffset() - int64(len(tok.String()))
+		return nil, fmt.Errorf("at offset %d: only integer numbers are supported, got: %s", start, tok.String())
+	case bool:
+		return object.StaticBool(tok), nil
+	case nil:
+		return &object.StaticNull, nil
+	}
+	return nil, fmt.Errorf("at offset %d: unexpected token: %v", offset, tok)
+}
+
+// encodeJSON converts an object into a value which can be marshaled by encoding/json
+func encodeJSON(obj object.Object, depth int) (interface{}, error) {
+	if depth > maxJSONDepth {
+		return nil, fmt.Errorf("value is nested too deep, does it contain itself?")
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Number:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		return encodeJSONItems(obj.Items, depth)
+	case *object.Tuple:
+		return encodeJSONItems(obj.Values, depth)
+	case *object.Map:
+		ret := make(map[string]interface{}, len(obj.Fields))
+		for _, item := range obj.Fields {
+			var key string
+			switch k := item.Key.(type) {
+			case *object.String:
+				key = k.Value
+			case *object.Number:
+				key = strconv.Itoa(k.Value)
+			default:
+				return nil, fmt.Errorf("map keys must be strings or numbers, got: %s", item.Key.Type().String())
+			}
+			value, err := encodeJSON(item.Value, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			ret[key] = value
+		}
+		return ret, nil
+	case *object.Struct:
+		ret := make(map[string]interface{}, len(obj.Fields))
+		for key, field := range obj.Fields {
+			value, err := encodeJSON(field, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			ret[key] = value
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("cannot convert a value of type %s to json", obj.Type().String())
+}
+
+func encodeJSONItems(items []object.Object, depth int) ([]interface{}, error) {
+	ret := make([]interface{}, len(items))
+	for i, item := range items {
+		value, err := encodeJSON(item, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = value
+	}
+	return ret, nil
+}
+
+var jsonModule = map[string]BuiltinFunction{
+	"parse": {
+		Arguments: []string{"s"},
+		Body: func(args map[string]object.Object) object.Object {
+			s, argErr := stringArg(args, "s")
+			if argErr != nil {
+				return argErr
+			}
+
+			d := json.NewDecoder(strings.NewReader(s))
+			d.UseNumber()
+			ret, err := decodeJSON(d)
+			if err != nil {
+				return &object.Error{Msg: "json: " + err.Error()}
+			}
+			if _, err := d.Token(); err != io.EOF {
+				return &object.Error{Msg: fmt.Sprintf("json: at offset %d: unexpected data after the value", d.InputOffset())}
+			}
+			return returnValue(ret)
+		},
+	},
+	"stringify": {
+		Arguments: []string{"v", "indent"},
+		Body: func(args map[string]object.Object) object.Object {
+			indent, argErr := numberArg(args, "indent")
+			if argErr != nil {
+				return argErr
+			}
+			if indent < 0 {
+				return &object.Error{Msg: "argument indent: must not be negative"}
+			}
+
+			value, err := encodeJSON(args["v"], 0)
+			if err != nil {
+				return &object.Error{Msg: "json: " + err.Error()}
+			}
+
+			var b bytes.Buffer
+			e := json.NewEncoder(&b)
+			e.SetEscapeHTML(false)
+			e.SetIndent("", strings.Repeat(" ", indent))
+			if err := e.Encode(value); err != nil {
+				return &object.Error{Msg: "json: " + err.Error()}
+			}
+			return returnValue(&object.String{Value: strings.TrimSuffix(b.String(), "\n")})
+		},
+	},
+}
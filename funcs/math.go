@@ -0,0 +1,277 @@
+package funcs
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"ryanlang/object"
+)
+
+var errOverflow = errors.New("integer overflow")
+
+// mul multiplies two numbers, ok is false if the result doesn't fit into an int
+func mul(x int, y int) (ret int, ok bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	ret = x * y
+	if ret/y != x || (x == -1 && y == math.MinInt) || (y == -1 && x == math.MinInt) {
+		return 0, false
+	}
+	return ret, true
+}
+
+// pow raises a to the power of b by repeated squaring, it fails instead of overflowing
+func pow(a int, b int) (int, error) {
+	if b < 0 {
+		return 0, errors.New("negative exponent")
+	}
+	ret := 1
+	ok := true
+	for {
+		if b&1 == 1 {
+			if ret, ok = mul(ret, a); !ok {
+				return 0, errOverflow
+			}
+		}
+		b >>= 1
+		if b == 0 {
+			return ret, nil
+		}
+		if a, ok = mul(a, a); !ok {
+			return 0, errOverflow
+		}
+	}
+}
+
+// isqrt returns the largest number whose square is not greater than n
+func isqrt(n int) int {
+	r := int(math.Sqrt(float64(n)))
+	// the float result can be off by one for large numbers, comparisons are done by division to avoid overflows
+	for r > 0 && r > n/r {
+		r--
+	}
+	for r+1 <= n/(r+1) {
+		r++
+	}
+	return r
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// numberFunc makes a built-in function of a single number argument
+func numberFunc(f func(n int) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"n"},
+		Body: func(args map[string]object.Object) object.Object {
+			n, err := numberArg(args, "n")
+			if err != nil {
+				return err
+			}
+			return returnResult(f(n))
+		},
+	}
+}
+
+// numberFunc2 makes a built-in function of two number arguments
+func numberFunc2(f func(a int, b int) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"a", "b"},
+		Body: func(args map[string]object.Object) object.Object {
+			a, err := numberArg(args, "a")
+			if err != nil {
+				return err
+			}
+			b, err := numberArg(args, "b")
+			if err != nil {
+				return err
+			}
+			return returnResult(f(a, b))
+		},
+	}
+}
+
+// numbersFunc makes a built-in function of an array of numbers
+func numbersFunc(f func(a []int) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"a"},
+		Body: func(args map[string]object.Object) object.Object {
+			items, err := arrayArg(args, "a")
+			if err != nil {
+				return err
+			}
+			a := make([]int, len(items))
+			for i, item := range items {
+				n, ok := item.(*object.Number)
+				if !ok {
+					return argError("a", "array of numbers", item)
+				}
+				a[i] = n.Value
+			}
+			return returnResult(f(a))
+		},
+	}
+}
+
+// extremum makes a built-in function returning the smallest or the largest number of an array
+func extremum(name string, less func(a int, b int) bool) BuiltinFunction {
+	return numbersFunc(func(a []int) object.Object {
+		if len(a) == 0 {
+			return &object.Error{Msg: name + " of an empty array"}
+		}
+		ret := a[0]
+		for _, n := range a[1:] {
+			if less(n, ret) {
+				ret = n
+			}
+		}
+		return &object.Number{Value: ret}
+	})
+}
+
+var mathModule = map[string]BuiltinFunction{
+	"abs": numberFunc(func(n int) object.Object {
+		if n < 0 {
+			return &object.Number{Value: -n}
+		}
+		return &object.Number{Value: n}
+	}),
+	"sign": numberFunc(func(n int) object.Object {
+		switch {
+		case n < 0:
+			return &object.Number{Value: -1}
+		case n > 0:
+			return &object.Number{Value: 1}
+		}
+		return &object.Number{Value: 0}
+	}),
+	"pow": numberFunc2(func(a int, b int) object.Object {
+		ret, err := pow(a, b)
+		if err != nil {
+			return &object.Error{Msg: err.Error()}
+		}
+		return &object.Number{Value: ret}
+	}),
+	"ipow": numberFunc2(func(a int, b int) object.Object {
+		// same as pow, but wraps around on overflow like the other arithmetic operators do
+		if b < 0 {
+			return &object.Error{Msg: "negative exponent"}
+		}
+		ret := 1
+		for ; b > 0; b >>= 1 {
+			if b&1 == 1 {
+				ret *= a
+			}
+			a *= a
+		}
+		return &object.Number{Value: ret}
+	}),
+	"modpow": {
+		Arguments: []string{"a", "b", "m"},
+		Body: func(args map[string]object.Object) object.Object {
+			a, err := numberArg(args, "a")
+			if err != nil {
+				return err
+			}
+			b, err := numberArg(args, "b")
+			if err != nil {
+				return err
+			}
+			m, err := numberArg(args, "m")
+			if err != nil {
+				return err
+			}
+			if b < 0 {
+				return &object.Error{Msg: "negative exponent"}
+			}
+			if m <= 0 {
+				return &object.Error{Msg: "argument m: modulus must be positive"}
+			}
+			// the intermediate products don't fit into an int for large moduli
+			ret := new(big.Int).Exp(big.NewInt(int64(a)), big.NewInt(int64(b)), big.NewInt(int64(m)))
+			return returnValue(&object.Number{Value: int(ret.Int64())})
+		},
+	},
+	"gcd": numberFunc2(func(a int, b int) object.Object {
+		return &object.Number{Value: gcd(a, b)}
+	}),
+	"lcm": numberFunc2(func(a int, b int) object.Object {
+		if a == 0 || b == 0 {
+			return &object.Number{Value: 0}
+		}
+		ret, ok := mul(a/gcd(a, b), b)
+		if !ok {
+			return &object.Error{Msg: errOverflow.Error()}
+		}
+		if ret < 0 {
+			ret = -ret
+		}
+		return &object.Number{Value: ret}
+	}),
+	"sqrt": numberFunc(func(n int) object.Object {
+		if n < 0 {
+			return &object.Error{Msg: "square root of a negative number"}
+		}
+		r := isqrt(n)
+		if r*r != n {
+			return &object.Error{Msg: "not a perfect square, use isqrt to round down"}
+		}
+		return &object.Number{Value: r}
+	}),
+	"isqrt": numberFunc(func(n int) object.Object {
+		if n < 0 {
+			return &object.Error{Msg: "square root of a negative number"}
+		}
+		return &object.Number{Value: isqrt(n)}
+	}),
+	"clamp": {
+		Arguments: []string{"n", "lo", "hi"},
+		Body: func(args map[string]object.Object) object.Object {
+			n, err := numberArg(args, "n")
+			if err != nil {
+				return err
+			}
+			lo, err := numberArg(args, "lo")
+			if err != nil {
+				return err
+			}
+			hi, err := numberArg(args, "hi")
+			if err != nil {
+				return err
+			}
+			if lo > hi {
+				return &object.Error{Msg: "clamp: lo is greater than hi"}
+			}
+			if n < lo {
+				n = lo
+			} else if n > hi {
+				n = hi
+			}
+			return returnValue(&object.Number{Value: n})
+		},
+	},
+	"sum": numbersFunc(func(a []int) object.Object {
+		ret := 0
+		for _, n := range a {
+			ret += n
+		}
+		return &object.Number{Value: ret}
+	}),
+	"product": numbersFunc(func(a []int) object.Object {
+		ret := 1
+		for _, n := range a {
+			ret *= n
+		}
+		return &object.Number{Value: ret}
+	}),
+	"min": extremum("min", func(a int, b int) bool { return a < b }),
+	"max": extremum("max", func(a int, b int) bool { return a > b }),
+}
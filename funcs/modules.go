@@ -0,0 +1,192 @@
+package funcs
+
+import (
+	"fmt"
+	"io"
+	"ryanlang/lexer"
+	"ryanlang/object"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type BuiltinFunction struct {
+	Arguments []string
+	Body      func(args map[string]object.Object) object.Object
+
+	// ContextBody is used instead of Body by built-in functions which need access to the running program
+	ContextBody func(ctx Context, args map[string]object.Object) object.Object
+
+	// Optional is the number of trailing arguments which can be omitted, they are null then
+	Optional int
+
+	// Variadic functions take any number of extra arguments, which are passed as an array in the last argument
+	Variadic bool
+}
+
+// Accepts reports whether the function can be called with n arguments
+func (b BuiltinFunction) Accepts(n int) bool {
+	if b.Variadic {
+		return n >= len(b.Arguments)-1-b.Optional
+	}
+	return n <= len(b.Arguments) && n >= len(b.Arguments)-b.Optional
+}
+
+// Arity describes the number of accepted arguments for error messages, e.g. "2" or "1 to 2"
+func (b BuiltinFunction) Arity() string {
+	if b.Variadic {
+		return fmt.Sprintf("at least %d", len(b.Arguments)-1-b.Optional)
+	}
+	if b.Optional == 0 {
+		return strconv.Itoa(len(b.Arguments))
+	}
+	return fmt.Sprintf("%d to %d", len(b.Arguments)-b.Optional, len(b.Arguments))
+}
+
+// Pack arranges the accepted arguments of a call one per name in Arguments: omitted optional arguments become null
+// and the extra arguments of a variadic function are put into an array
+func (b BuiltinFunction) Pack(args []object.Object) []object.Object {
+	fixed := len(b.Arguments)
+	if b.Variadic {
+		fixed--
+	}
+	ret := make([]object.Object, len(b.Arguments))
+	for i := 0; i < fixed; i++ {
+		if i < len(args) {
+			ret[i] = args[i]
+		} else {
+			ret[i] = &object.StaticNull
+		}
+	}
+	if b.Variadic {
+		extra := &object.Array{Items: []object.Object{}}
+		if len(args) > fixed {
+			extra.Items = append(extra.Items, args[fixed:]...)
+		}
+		ret[fixed] = extra
+	}
+	return ret
+}
+
+// Context is implemented by the engines running the program
+type Context interface {
+	// Call calls a function (a closure in the vm) with the given arguments and returns its result or an *object.Error
+	Call(fn object.Object, args ...object.Object) object.Object
+	// Stdout and Stderr are where the program's output goes
+	Stdout() io.Writer
+	Stderr() io.Writer
+	// Location is the location in the source of the call being executed, nil if unknown
+	Location() *lexer.Location
+	// Clock is the source of time
+	Clock() Clock
+	// Policy restricts what the program can do to the host
+	Policy() Policy
+}
+
+func (b BuiltinFunction) Invoke(ctx Context, args map[string]object.Object) object.Object {
+	if b.ContextBody != nil {
+		return b.ContextBody(ctx, args)
+	}
+	return b.Body(args)
+}
+
+// NativeModules are modules implemented in Go, they are imported by name, e.g. `import("strings")`.
+// Their functions are referred to as built-in functions named "module.function".
+var NativeModules = map[string]map[string]BuiltinFunction{
+	"strings": stringsModule,
+	"regex":   regexModule,
+	"json":    jsonModule,
+	"fs":      fsModule,
+	"stdin":   stdinModule,
+	"math":    mathModule,
+	"iter":    iterModule,
+	"grid":    gridModule,
+	"graph":   graphModule,
+	"time":    timeModule,
+	"random":  randomModule,
+	"crypto":  cryptoModule,
+	"hex":     hexModule,
+	"base64":  base64Module,
+	"http":    httpModule,
+}
+
+// LookupBuiltin finds a built-in function by its name, which is either a global one (e.g. "len")
+// or a function of a native module (e.g. "strings.trim")
+func LookupBuiltin(name string) (BuiltinFunction, bool) {
+	if f, ok := BuiltinFunctions[name]; ok {
+		return f, true
+	}
+	moduleName, funcName, ok := strings.Cut(name, ".")
+	if !ok {
+		return BuiltinFunction{}, false
+	}
+	f, ok := NativeModules[moduleName][funcName]
+	return f, ok
+}
+
+// NativeModuleFunctions returns the names of the functions of a native module in a stable order
+func NativeModuleFunctions(moduleName string) ([]string, bool) {
+	module, ok := NativeModules[moduleName]
+	if !ok {
+		return nil, false
+	}
+	names := make([]string, 0, len(module))
+	for name := range module {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+func argError(name string, expected string, actual object.Object) object.Object {
+	return &object.Error{Msg: fmt.Sprintf("argument %s: %s expected, got %s", name, expected, actual.Type().String())}
+}
+
+func stringArg(args map[string]object.Object, name string) (string, object.Object) {
+	v, ok := args[name].(*object.String)
+	if !ok {
+		return "", argError(name, "string", args[name])
+	}
+	return v.Value, nil
+}
+
+func numberArg(args map[string]object.Object, name string) (int, object.Object) {
+	v, ok := args[name].(*object.Number)
+	if !ok {
+		return 0, argError(name, "number", args[name])
+	}
+	return v.Value, nil
+}
+
+func arrayArg(args map[string]object.Object, name string) ([]object.Object, object.Object) {
+	v, ok := args[name].(*object.Array)
+	if !ok {
+		return nil, argError(name, "array", args[name])
+	}
+	return v.Items, nil
+}
+
+// funcArg accepts a function, i.e. a closure in the vm. Optional arguments which were omitted are returned as nil
+func funcArg(args map[string]object.Object, name string, optional bool) (object.Object, object.Object) {
+	switch arg := args[name].(type) {
+	case *object.Closure, *object.Function:
+		return arg, nil
+	case *object.Null:
+		if optional {
+			return nil, nil
+		}
+	}
+	return nil, argError(name, "function", args[name])
+}
+
+func returnValue(obj object.Object) object.Object {
+	return &object.ReturnObject{Obj: obj}
+}
+
+// returnResult is returnValue for results which can be errors, they must stay bare for the engines to stop on them
+func returnResult(obj object.Object) object.Object {
+	if object.IsError(obj) {
+		return obj
+	}
+	return returnValue(obj)
+}
@@ -0,0 +1,235 @@
+package funcs
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"ryanlang/object"
+)
+
+// DefaultSeed seeds the generator used by the random module unless the host calls SeedRandom, so that runs are
+// reproducible
+const DefaultSeed = 1
+
+// randomFloatScale is what random.float returns fractions in: numbers are integers, so a fraction is a number of
+// billionths
+const randomFloatScale = 1000000000
+
+type generator struct {
+	*rand.Rand
+	seed int64
+}
+
+func newGenerator(seed int64) *generator {
+	return &generator{Rand: rand.New(rand.NewSource(seed)), seed: seed}
+}
+
+func (g *generator) String() string {
+	return fmt.Sprintf("seed %d", g.seed)
+}
+
+func (g *generator) Seed(seed int64) {
+	g.Rand.Seed(seed)
+	g.seed = seed
+}
+
+// intn returns a number in [0, n), n is unsigned so that the whole range of numbers fits
+func (g *generator) intn(n uint64) uint64 {
+	if n <= math.MaxInt64 {
+		return uint64(g.Int63n(int64(n)))
+	}
+	for {
+		if v := g.Uint64(); v < n {
+			return v
+		}
+	}
+}
+
+// defaultGenerator is used by the functions of the random module when no generator is passed to them
+var defaultGenerator = newGenerator(DefaultSeed)
+
+// SeedRandom reseeds the default generator of the random module
+func SeedRandom(seed int64) {
+	defaultGenerator.Seed(seed)
+}
+
+// generatorArg accepts a generator created by random.new, the default generator is used when the argument is omitted
+func generatorArg(args map[string]object.Object, name string) (*generator, object.Object) {
+	switch arg := args[name].(type) {
+	case *object.Null:
+		return defaultGenerator, nil
+	case *object.Native:
+		if g, ok := arg.Value.(*generator); ok {
+			return g, nil
+		}
+	}
+	return nil, argError(name, "random generator", args[name])
+}
+
+// sequence gives random access to the items of a collection, ranges are not materialized
+func sequence(c object.Object) (n int, at func(i int) object.Object, err object.Object) {
+	if r, ok := c.(*object.Range); ok {
+		return r.Len(), func(i int) object.Object { return &object.Number{Value: r.At(i)} }, nil
+	}
+	items, err := collectionItems(c)
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(items), func(i int) object.Object { return items[i] }, nil
+}
+
+// sample picks k distinct positions out of n in a random order with a partial Fisher-Yates shuffle, only the
+// swapped positions are remembered
+func sample(g *generator, n int, k int) []int {
+	swapped := map[int]int{}
+	pos := func(i int) int {
+		if j, ok := swapped[i]; ok {
+			return j
+		}
+		return i
+	}
+	ret := make([]int, k)
+	for i := 0; i < k; i++ {
+		j := i + int(g.intn(uint64(n-i)))
+		ret[i] = pos(j)
+		swapped[j] = pos(i)
+	}
+	return ret
+}
+
+var randomModule = map[string]BuiltinFunction{
+	"new": {
+		Arguments: []string{"seed"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			// without a seed, the new generator is seeded from the default one, which keeps runs reproducible
+			seed := defaultGenerator.Int63()
+			if args["seed"].Type() != object.NULL {
+				n, err := numberArg(args, "seed")
+				if err != nil {
+					return err
+				}
+				seed = int64(n)
+			}
+			return returnValue(&object.Native{Name: "random", Value: newGenerator(seed)})
+		},
+	},
+	"seed": {
+		Arguments: []string{"seed", "rng"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			seed, err := numberArg(args, "seed")
+			if err != nil {
+				return err
+			}
+			g, err := generatorArg(args, "rng")
+			if err != nil {
+				return err
+			}
+			g.Seed(int64(seed))
+			return returnValue(&object.StaticNull)
+		},
+	},
+	"int": {
+		Arguments: []string{"lo", "hi", "rng"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			lo, err := numberArg(args, "lo")
+			if err != nil {
+				return err
+			}
+			hi, err := numberArg(args, "hi")
+			if err != nil {
+				return err
+			}
+			g, err := generatorArg(args, "rng")
+			if err != nil {
+				return err
+			}
+			if hi < lo {
+				return &object.Error{Msg: fmt.Sprintf("empty interval: %d to %d", lo, hi)}
+			}
+			span := uint64(hi-lo) + 1
+			if span == 0 {
+				// every number is in the interval
+				return returnValue(&object.Number{Value: int(g.Uint64())})
+			}
+			return returnValue(&object.Number{Value: lo + int(g.intn(span))})
+		},
+	},
+	"float": {
+		Arguments: []string{"rng"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := generatorArg(args, "rng")
+			if err != nil {
+				return err
+			}
+			return returnValue(&object.Number{Value: g.Intn(randomFloatScale)})
+		},
+	},
+	"choice": {
+		Arguments: []string{"c", "rng"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := generatorArg(args, "rng")
+			if err != nil {
+				return err
+			}
+			n, at, err := sequence(args["c"])
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return &object.Error{Msg: "cannot choose from an empty collection"}
+			}
+			return returnValue(at(int(g.intn(uint64(n)))))
+		},
+	},
+	"shuffle": {
+		Arguments: []string{"c", "rng"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			g, err := generatorArg(args, "rng")
+			if err != nil {
+				return err
+			}
+			items, err := collectionItems(args["c"])
+			if err != nil {
+				return err
+			}
+			ret := &object.Array{Items: make([]object.Object, len(items))}
+			copy(ret.Items, items)
+			g.Shuffle(len(ret.Items), func(i, j int) {
+				ret.Items[i], ret.Items[j] = ret.Items[j], ret.Items[i]
+			})
+			return returnValue(ret)
+		},
+	},
+	"sample": {
+		Arguments: []string{"c", "k", "rng"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			k, err := numberArg(args, "k")
+			if err != nil {
+				return err
+			}
+			g, err := generatorArg(args, "rng")
+			if err != nil {
+				return err
+			}
+			n, at, err := sequence(args["c"])
+			if err != nil {
+				return err
+			}
+			if k < 0 || k > n {
+				return &object.Error{Msg: fmt.Sprintf("cannot sample %d items out of %d", k, n)}
+			}
+			ret := &object.Array{Items: make([]object.Object, k)}
+			for i, j := range sample(g, n, k) {
+				ret.Items[i] = at(j)
+			}
+			return returnValue(ret)
+		},
+	},
+}
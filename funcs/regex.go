@@ -0,0 +1,169 @@
+package funcs
+
+import (
+	"regexp"
+	"ryanlang/object"
+	"strings"
+)
+
+const regexCacheSize = 256
+
+// regexCache keeps patterns passed as strings compiled, so that using a pattern in a loop doesn't recompile it every time
+var regexCache = map[string]*regexp.Regexp{}
+
+// regexArg accepts either a pattern compiled by regex.compile or a string with a pattern
+func regexArg(args map[string]object.Object, name string) (*regexp.Regexp, object.Object) {
+	switch arg := args[name].(type) {
+	case *object.Native:
+		if re, ok := arg.Value.(*regexp.Regexp); ok {
+			return re, nil
+		}
+	case *object.String:
+		if re, ok := regexCache[arg.Value]; ok {
+			return re, nil
+		}
+		re, err := regexp.Compile(arg.Value)
+		if err != nil {
+			return nil, &object.Error{Msg: "argument " + name + ": invalid pattern: " + err.Error()}
+		}
+		if len(regexCache) >= regexCacheSize {
+			regexCache = map[string]*regexp.Regexp{}
+		}
+		regexCache[arg.Value] = re
+		return re, nil
+	}
+	return nil, argError(name, "regex or string", args[name])
+}
+
+// regexFunc makes a built-in function of a pattern and a string
+func regexFunc(f func(re *regexp.Regexp, s string) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"re", "s"},
+		Body: func(args map[string]object.Object) object.Object {
+			re, err := regexArg(args, "re")
+			if err != nil {
+				return err
+			}
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			return returnResult(f(re, s))
+		},
+	}
+}
+
+// submatches converts submatch indices into an array of the matched strings, groups which did not participate in the match are null
+func submatches(s string, indices []int) *object.Array {
+	ret := &object.Array{Items: make([]object.Object, len(indices)/2)}
+	for i := range ret.Items {
+		if indices[2*i] < 0 {
+			ret.Items[i] = &object.StaticNull
+		} else {
+			ret.Items[i] = &object.String{Value: s[indices[2*i]:indices[2*i+1]]}
+		}
+	}
+	return ret
+}
+
+var regexModule = map[string]BuiltinFunction{
+	"compile": {
+		Arguments: []string{"pattern"},
+		Body: func(args map[string]object.Object) object.Object {
+			pattern, err := stringArg(args, "pattern")
+			if err != nil {
+				return err
+			}
+			re, compileErr := regexp.Compile(pattern)
+			if compileErr != nil {
+				return &object.Error{Msg: "invalid pattern: " + compileErr.Error()}
+			}
+			return returnValue(&object.Native{Name: "regex", Value: re})
+		},
+	},
+	"match": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		return object.StaticBool(re.MatchString(s))
+	}),
+	"find": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		loc := re.FindStringIndex(s)
+		if loc == nil {
+			return &object.StaticNull
+		}
+		return &object.String{Value: s[loc[0]:loc[1]]}
+	}),
+	"findall": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		return stringsArray(re.FindAllString(s, -1))
+	}),
+	"submatch": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		indices := re.FindStringSubmatchIndex(s)
+		if indices == nil {
+			return &object.StaticNull
+		}
+		return submatches(s, indices)
+	}),
+	"submatchall": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		ret := &object.Array{}
+		for _, indices := range re.FindAllStringSubmatchIndex(s, -1) {
+			ret.Items = append(ret.Items, submatches(s, indices))
+		}
+		return ret
+	}),
+	"groups": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		indices := re.FindStringSubmatchIndex(s)
+		if indices == nil {
+			return &object.StaticNull
+		}
+		groups := submatches(s, indices)
+		ret := &object.Map{Fields: map[string]object.MapItem{}}
+		for i, name := range re.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			key := &object.String{Value: name}
+			ret.Fields[key.Hash()] = object.MapItem{Key: key, Value: groups.Items[i]}
+		}
+		return ret
+	}),
+	"split": regexFunc(func(re *regexp.Regexp, s string) object.Object {
+		return stringsArray(re.Split(s, -1))
+	}),
+	"replace": {
+		Arguments: []string{"re", "s", "repl"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			re, err := regexArg(args, "re")
+			if err != nil {
+				return err
+			}
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+
+			switch repl := args["repl"].(type) {
+			case *object.String:
+				// $1 or ${name} in the replacement refer to the groups
+				return returnValue(&object.String{Value: re.ReplaceAllString(s, repl.Value)})
+			case *object.Closure, *object.Function:
+				// the callback receives an array of the match and its groups, and returns the replacement
+				var b strings.Builder
+				last := 0
+				for _, indices := range re.FindAllStringSubmatchIndex(s, -1) {
+					b.WriteString(s[last:indices[0]])
+					replacement := ctx.Call(repl, submatches(s, indices))
+					if object.IsError(replacement) {
+						return replacement
+					}
+					str, ok := replacement.(*object.String)
+					if !ok {
+						return &object.Error{Msg: "replacement callback must return a string, got: " + replacement.Type().String()}
+					}
+					b.WriteString(str.Value)
+					last = indices[1]
+				}
+				b.WriteString(s[last:])
+				return returnValue(&object.String{Value: b.String()})
+			}
+			return argError("repl", "string or function", args["repl"])
+		},
+	},
+}
@@ -0,0 +1,165 @@
+package funcs
+
+import (
+	"errors"
+	"fmt"
+	"ryanlang/object"
+	"sort"
+	"strings"
+)
+
+// compare implements the default ordering used for sorting: numbers and strings are ordered as by the < operator,
+// arrays and tuples are compared item by item
+func compare(a object.Object, b object.Object) (int, error) {
+	switch a := a.(type) {
+	case *object.Number:
+		if b, ok := b.(*object.Number); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, nil
+			case a.Value > b.Value:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	case *object.Array:
+		if b, ok := b.(*object.Array); ok {
+			return compareItems(a.Items, b.Items)
+		}
+	case *object.Tuple:
+		if b, ok := b.(*object.Tuple); ok {
+			return compareItems(a.Values, b.Values)
+		}
+	}
+	return 0, fmt.Errorf("don't know how to compare types: %s, %s", a.Type().String(), b.Type().String())
+}
+
+func compareItems(a []object.Object, b []object.Object) (int, error) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c, err := compare(a[i], b[i]); err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return len(a) - len(b), nil
+}
+
+// callbackError carries an error of the comparator through the sorting, it's returned to the program as it is
+type callbackError struct {
+	err *object.Error
+}
+
+func (e callbackError) Error() string {
+	return e.err.String()
+}
+
+// sortError makes the error returned by sortStable an error of the named built-in function
+func sortError(name string, err error) object.Object {
+	var cbErr callbackError
+	if errors.As(err, &cbErr) {
+		return cbErr.err
+	}
+	return &object.Error{Msg: name + ": " + err.Error()}
+}
+
+// comparator calls a user function comparing two items, which returns either a number (negative if a goes before b)
+// or a bool (true if a goes before b)
+func comparator(ctx Context, cmp object.Object) func(a object.Object, b object.Object) (int, error) {
+	return func(a object.Object, b object.Object) (int, error) {
+		ret := ctx.Call(cmp, a, b)
+		switch ret := ret.(type) {
+		case *object.Error:
+			return 0, callbackError{err: ret}
+		case *object.Number:
+			return ret.Value, nil
+		case *object.Boolean:
+			if ret.Value {
+				return -1, nil
+			}
+			return 0, nil
+		}
+		return 0, fmt.Errorf("comparator must return a number or a bool, got: %s", ret.Type().String())
+	}
+}
+
+// sortStable sorts a copy of the items, stopping at the first error
+func sortStable(items []object.Object, keys []object.Object, cmp func(a object.Object, b object.Object) (int, error)) ([]object.Object, error) {
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	var err error
+	sort.SliceStable(idx, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var c int
+		c, err = cmp(keys[idx[i]], keys[idx[j]])
+		return c < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]object.Object, len(items))
+	for i, k := range idx {
+		ret[i] = items[k]
+	}
+	return ret, nil
+}
+
+var sortBuiltin = BuiltinFunction{
+	Arguments: []string{"a", "cmp"},
+	Optional:  1,
+	ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+		a, err := arrayArg(args, "a")
+		if err != nil {
+			return err
+		}
+		cmp := compare
+		switch args["cmp"].(type) {
+		case *object.Null:
+		case *object.Closure, *object.Function:
+			cmp = comparator(ctx, args["cmp"])
+		default:
+			return argError("cmp", "function", args["cmp"])
+		}
+
+		sorted, sortErr := sortStable(a, a, cmp)
+		if sortErr != nil {
+			return sortError("sort", sortErr)
+		}
+		return returnValue(&object.Array{Items: sorted})
+	},
+}
+
+var sortByBuiltin = BuiltinFunction{
+	Arguments: []string{"a", "key"},
+	ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+		a, err := arrayArg(args, "a")
+		if err != nil {
+			return err
+		}
+		switch args["key"].(type) {
+		case *object.Closure, *object.Function:
+		default:
+			return argError("key", "function", args["key"])
+		}
+
+		// every key is computed once rather than on every comparison
+		keys := make([]object.Object, len(a))
+		for i, item := range a {
+			keys[i] = ctx.Call(args["key"], item)
+			if object.IsError(keys[i]) {
+				return keys[i]
+			}
+		}
+		sorted, sortErr := sortStable(a, keys, compare)
+		if sortErr != nil {
+			return sortError("sortby", sortErr)
+		}
+		return returnValue(&object.Array{Items: sorted})
+	},
+}
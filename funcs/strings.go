@@ -0,0 +1,283 @@
+package funcs
+
+import (
+	"fmt"
+	"ryanlang/object"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxStringSize limits the length in bytes of the strings built from a count, a huge count would crash the interpreter
+// trying to allocate them
+const maxStringSize = 1 << 30
+
+// checkStringSize reports an error if n repetitions of a string of size bytes and a prefix of extra bytes would be longer
+// than maxStringSize, the comparisons cannot overflow
+func checkStringSize(arg string, size int, n int, extra int) *object.Error {
+	if size > 0 && n > (maxStringSize-extra)/size {
+		return &object.Error{Msg: fmt.Sprintf("argument %s: the string would be longer than %d bytes", arg, maxStringSize)}
+	}
+	return nil
+}
+
+// stringFunc makes a built-in function of a single string argument
+func stringFunc(f func(s string) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"s"},
+		Body: func(args map[string]object.Object) object.Object {
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			return returnResult(f(s))
+		},
+	}
+}
+
+// stringFunc2 makes a built-in function of two string arguments
+func stringFunc2(arg2 string, f func(s string, t string) object.Object) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"s", arg2},
+		Body: func(args map[string]object.Object) object.Object {
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			t, err := stringArg(args, arg2)
+			if err != nil {
+				return err
+			}
+			return returnResult(f(s, t))
+		},
+	}
+}
+
+// stringClass makes a built-in function checking that a string is not empty and all its characters satisfy f
+func stringClass(f func(r rune) bool) BuiltinFunction {
+	return stringFunc(func(s string) object.Object {
+		if s == "" {
+			return &object.StaticFalse
+		}
+		for _, r := range s {
+			if !f(r) {
+				return &object.StaticFalse
+			}
+		}
+		return &object.StaticTrue
+	})
+}
+
+// charIndex converts a byte offset into the string into the number of characters before it, -1 stays as is
+func charIndex(s string, i int) int {
+	if i < 0 {
+		return i
+	}
+	return utf8.RuneCountInString(s[:i])
+}
+
+// extendsCluster reports whether the rune is attached to the preceding one when they are displayed
+func extendsCluster(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) || // combining marks, including variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji skin tone modifiers
+		r == 0x200D // zero width joiner
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// graphemes splits a string into user-perceived characters. It's a simplification of the Unicode rules: combining
+// marks and emoji modifiers stay with the preceding character, ZWJ sequences and flags (pairs of regional
+// indicators) are kept together, and so is \r\n.
+func graphemes(s string) []string {
+	ret := []string{}
+	start := 0
+	var prev rune
+	flag := false // the previous rune is the first half of a flag
+	for i, r := range s {
+		joined := i > 0 && (extendsCluster(r) || prev == 0x200D || (prev == '\r' && r == '\n') ||
+			(flag && isRegionalIndicator(r)))
+		if i > 0 && !joined {
+			ret = append(ret, s[start:i])
+			start = i
+		}
+		flag = isRegionalIndicator(r) && !(joined && flag)
+		prev = r
+	}
+	if start < len(s) {
+		ret = append(ret, s[start:])
+	}
+	return ret
+}
+
+func pad(left bool) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{"s", "width", "pad"},
+		Body: func(args map[string]object.Object) object.Object {
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			width, err := numberArg(args, "width")
+			if err != nil {
+				return err
+			}
+			p, err := stringArg(args, "pad")
+			if err != nil {
+				return err
+			}
+			if len([]rune(p)) != 1 {
+				return &object.Error{Msg: "argument pad: a single character expected, got: " + strconv.Quote(p)}
+			}
+			if width <= len([]rune(s)) {
+				return returnValue(&object.String{Value: s})
+			}
+			n := width - len([]rune(s))
+			if err := checkStringSize("width", len(p), n, len(s)); err != nil {
+				return err
+			}
+			if left {
+				return returnValue(&object.String{Value: strings.Repeat(p, n) + s})
+			}
+			return returnValue(&object.String{Value: s + strings.Repeat(p, n)})
+		},
+	}
+}
+
+func stringsArray(items []string) *object.Array {
+	ret := &object.Array{Items: make([]object.Object, len(items))}
+	for i, s := range items {
+		ret.Items[i] = &object.String{Value: s}
+	}
+	return ret
+}
+
+var stringsModule = map[string]BuiltinFunction{
+	"trim": stringFunc(func(s string) object.Object {
+		return &object.String{Value: strings.TrimSpace(s)}
+	}),
+	"ltrim": stringFunc(func(s string) object.Object {
+		return &object.String{Value: strings.TrimLeftFunc(s, unicode.IsSpace)}
+	}),
+	"rtrim": stringFunc(func(s string) object.Object {
+		return &object.String{Value: strings.TrimRightFunc(s, unicode.IsSpace)}
+	}),
+	"trimprefix": stringFunc2("prefix", func(s string, prefix string) object.Object {
+		return &object.String{Value: strings.TrimPrefix(s, prefix)}
+	}),
+	"trimsuffix": stringFunc2("suffix", func(s string, suffix string) object.Object {
+		return &object.String{Value: strings.TrimSuffix(s, suffix)}
+	}),
+	"upper": stringFunc(func(s string) object.Object {
+		return &object.String{Value: strings.ToUpper(s)}
+	}),
+	"lower": stringFunc(func(s string) object.Object {
+		return &object.String{Value: strings.ToLower(s)}
+	}),
+	"reverse": stringFunc(func(s string) object.Object {
+		r := []rune(s)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return &object.String{Value: string(r)}
+	}),
+	"fields": stringFunc(func(s string) object.Object {
+		return stringsArray(strings.Fields(s))
+	}),
+	"split": stringFunc2("sep", func(s string, sep string) object.Object {
+		return stringsArray(strings.Split(s, sep))
+	}),
+	"contains": stringFunc2("sub", func(s string, sub string) object.Object {
+		return object.StaticBool(strings.Contains(s, sub))
+	}),
+	"index": stringFunc2("sub", func(s string, sub string) object.Object {
+		return &object.Number{Value: charIndex(s, strings.Index(s, sub))}
+	}),
+	"lastindex": stringFunc2("sub", func(s string, sub string) object.Object {
+		return &object.Number{Value: charIndex(s, strings.LastIndex(s, sub))}
+	}),
+	"graphemes": stringFunc(func(s string) object.Object {
+		return stringsArray(graphemes(s))
+	}),
+	"count": stringFunc2("sub", func(s string, sub string) object.Object {
+		return &object.Number{Value: strings.Count(s, sub)}
+	}),
+	"startswith": stringFunc2("prefix", func(s string, prefix string) object.Object {
+		return object.StaticBool(strings.HasPrefix(s, prefix))
+	}),
+	"endswith": stringFunc2("suffix", func(s string, suffix string) object.Object {
+		return object.StaticBool(strings.HasSuffix(s, suffix))
+	}),
+	"replace": {
+		Arguments: []string{"s", "old", "new"},
+		Body: func(args map[string]object.Object) object.Object {
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			old, err := stringArg(args, "old")
+			if err != nil {
+				return err
+			}
+			new, err := stringArg(args, "new")
+			if err != nil {
+				return err
+			}
+			return returnValue(&object.String{Value: strings.ReplaceAll(s, old, new)})
+		},
+	},
+	"join": {
+		Arguments: []string{"a", "sep"},
+		Body: func(args map[string]object.Object) object.Object {
+			a, err := arrayArg(args, "a")
+			if err != nil {
+				return err
+			}
+			sep, err := stringArg(args, "sep")
+			if err != nil {
+				return err
+			}
+			items := make([]string, len(a))
+			for i, item := range a {
+				s, ok := item.(*object.String)
+				if !ok {
+					return argError("a", "array of strings", item)
+				}
+				items[i] = s.Value
+			}
+			return returnValue(&object.String{Value: strings.Join(items, sep)})
+		},
+	},
+	"repeat": {
+		Arguments: []string{"s", "n"},
+		Body: func(args map[string]object.Object) object.Object {
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			n, err := numberArg(args, "n")
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return &object.Error{Msg: "argument n: cannot repeat a string a negative number of times"}
+			}
+			if err := checkStringSize("n", len(s), n, 0); err != nil {
+				return err
+			}
+			return returnValue(&object.String{Value: strings.Repeat(s, n)})
+		},
+	},
+	"padleft":  pad(true),
+	"padright": pad(false),
+	"isdigit":  stringClass(unicode.IsDigit),
+	"isalpha":  stringClass(unicode.IsLetter),
+	"isalnum": stringClass(func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}),
+	"isspace": stringClass(unicode.IsSpace),
+	"isupper": stringClass(unicode.IsUpper),
+	"islower": stringClass(unicode.IsLower),
+}
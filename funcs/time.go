@@ -0,0 +1,149 @@
+package funcs
+
+import (
+	"ryanlang/object"
+	"strings"
+	"time"
+)
+
+// Clock is where the time module gets the time from. Embedders can replace it with a FakeClock to make programs
+// using the time deterministic.
+type Clock interface {
+	// Now is the wall clock time
+	Now() time.Time
+	// Monotonic is the time elapsed since an arbitrary moment, it never goes back
+	Monotonic() time.Duration
+	Sleep(d time.Duration)
+}
+
+type systemClock struct {
+	start time.Time
+}
+
+func (c systemClock) Now() time.Time           { return time.Now() }
+func (c systemClock) Monotonic() time.Duration { return time.Since(c.start) }
+func (c systemClock) Sleep(d time.Duration)    { time.Sleep(d) }
+
+// SystemClock is the real clock, the monotonic time is counted from the start of the process
+var SystemClock Clock = systemClock{start: time.Now()}
+
+// FakeClock stands still unless the program sleeps or Advance is called
+type FakeClock struct {
+	Start   time.Time
+	Elapsed time.Duration
+}
+
+func (c *FakeClock) Now() time.Time           { return c.Start.Add(c.Elapsed) }
+func (c *FakeClock) Monotonic() time.Duration { return c.Elapsed }
+func (c *FakeClock) Sleep(d time.Duration)    { c.Advance(d) }
+
+// Advance moves the clock forward
+func (c *FakeClock) Advance(d time.Duration) {
+	if d > 0 {
+		c.Elapsed += d
+	}
+}
+
+// timeLayouts are the named layouts accepted by format and parse, other layouts are in the Go notation,
+// e.g. "2006-01-02 15:04"
+var timeLayouts = map[string]string{
+	"":         time.RFC3339Nano,
+	"date":     "2006-01-02",
+	"time":     "15:04:05",
+	"datetime": "2006-01-02 15:04:05",
+	"rfc3339":  time.RFC3339,
+}
+
+func layoutArg(args map[string]object.Object, name string) (string, object.Object) {
+	if args[name].Type() == object.NULL {
+		return timeLayouts[""], nil
+	}
+	layout, err := stringArg(args, name)
+	if err != nil {
+		return "", err
+	}
+	if named, ok := timeLayouts[strings.ToLower(layout)]; ok {
+		return named, nil
+	}
+	return layout, nil
+}
+
+// clockFunc makes a built-in function returning a reading of the clock
+func clockFunc(f func(c Clock) int) BuiltinFunction {
+	return BuiltinFunction{
+		Arguments: []string{},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			return returnValue(&object.Number{Value: f(ctx.Clock())})
+		},
+	}
+}
+
+// timestamps are milliseconds since the unix epoch, durations are milliseconds
+var timeModule = map[string]BuiltinFunction{
+	"now": clockFunc(func(c Clock) int {
+		return int(c.Monotonic().Milliseconds())
+	}),
+	"nanos": clockFunc(func(c Clock) int {
+		return int(c.Monotonic().Nanoseconds())
+	}),
+	"unix": clockFunc(func(c Clock) int {
+		return int(c.Now().UnixMilli())
+	}),
+	"sleep": {
+		Arguments: []string{"ms"},
+		ContextBody: func(ctx Context, args map[string]object.Object) object.Object {
+			ms, err := numberArg(args, "ms")
+			if err != nil {
+				return err
+			}
+			if ms > 0 {
+				ctx.Clock().Sleep(time.Duration(ms) * time.Millisecond)
+			}
+			return returnValue(&object.StaticNull)
+		},
+	},
+	"format": {
+		Arguments: []string{"ts", "layout"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			ts, err := numberArg(args, "ts")
+			if err != nil {
+				return err
+			}
+			layout, err := layoutArg(args, "layout")
+			if err != nil {
+				return err
+			}
+			return returnValue(&object.String{Value: time.UnixMilli(int64(ts)).UTC().Format(layout)})
+		},
+	},
+	"parse": {
+		Arguments: []string{"s", "layout"},
+		Optional:  1,
+		Body: func(args map[string]object.Object) object.Object {
+			s, err := stringArg(args, "s")
+			if err != nil {
+				return err
+			}
+			layout, err := layoutArg(args, "layout")
+			if err != nil {
+				return err
+			}
+			t, parseErr := time.Parse(layout, s)
+			if parseErr != nil {
+				return &object.Error{Msg: parseErr.Error()}
+			}
+			return returnValue(&object.Number{Value: int(t.UnixMilli())})
+		},
+	},
+	"duration": stringFunc(func(s string) object.Object {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return &object.Error{Msg: err.Error()}
+		}
+		return &object.Number{Value: int(d.Milliseconds())}
+	}),
+	"formatduration": numberFunc(func(ms int) object.Object {
+		return &object.String{Value: (time.Duration(ms) * time.Millisecond).String()}
+	}),
+}
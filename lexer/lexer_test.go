@@ -151,6 +151,23 @@ func TestLexer(t *testing.T) {
 				Column: 33,
 			},
 		}}},
+		{s: "const c", tks: []Token{{
+			Kind:    TokenTypeConst,
+			Literal: "const",
+			Location: &Location{
+				File:   "(string input)",
+				Line:   1,
+				Column: 1,
+			},
+		}, {
+			Kind:    TokenTypeIdentifier,
+			Literal: "c",
+			Location: &Location{
+				File:   "(string input)",
+				Line:   1,
+				Column: 7,
+			},
+		}}},
 	}
 
 	for _, tt := range tc {
@@ -59,6 +59,10 @@ const (
 	TokenTypeExports
 	TokenTypeImport
 	TokenTypeMap
+	TokenTypeConst
+	TokenTypeDefer
+	TokenTypeNot
+	TokenTypePow
 )
 
 func (tk TokenKind) String() string {
@@ -163,6 +167,14 @@ func (tk TokenKind) String() string {
 		return "map"
 	case TokenTypeImport:
 		return "import"
+	case TokenTypeConst:
+		return "const"
+	case TokenTypeDefer:
+		return "defer"
+	case TokenTypeNot:
+		return "not"
+	case TokenTypePow:
+		return "**"
 	default:
 		return fmt.Sprintf("[%d]", tk)
 	}
@@ -184,6 +196,9 @@ var keywords = map[string]TokenKind{
 	"exports":  TokenTypeExports,
 	"import":   TokenTypeImport,
 	"map":      TokenTypeMap,
+	"const":    TokenTypeConst,
+	"defer":    TokenTypeDefer,
+	"not":      TokenTypeNot,
 }
 var tokens = []struct {
 	literal string
@@ -219,6 +234,7 @@ var tokens = []struct {
 	{"&&", TokenTypeLogicalAnd},
 	{"||", TokenTypeLogicalOr},
 	{"%", TokenTypeMod},
+	{"**", TokenTypePow},
 }
 
 func init() {
@@ -233,6 +249,12 @@ type Token struct {
 	Location *Location
 }
 
+// IsKeyword reports whether the token is a keyword
+func (t Token) IsKeyword() bool {
+	kind, ok := keywords[t.Literal]
+	return ok && kind == t.Kind
+}
+
 type Location struct {
 	File   string
 	Line   int
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime/pprof"
 	"ryanlang/compiler"
 	"ryanlang/eval"
+	"ryanlang/funcs"
 	"ryanlang/lexer"
 	"ryanlang/object"
 	"ryanlang/parser"
+	"ryanlang/typecheck"
 	"ryanlang/vm"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -61,9 +67,128 @@ func run(fn string, engine Engine) (object.Object, *eval.Evaluator) {
 	return evaled, nil
 }
 
+// check runs the static type checker over a module and reports whether it passed
+func check(fn string) bool {
+	f, err := os.Open(fn)
+	if err != nil {
+		panic(err)
+	}
+	l := lexer.New(f, fn)
+	p := parser.New(l)
+
+	errs := typecheck.Check(p.ReadModule(fn))
+	for _, e := range errs {
+		fmt.Println(e.Error())
+	}
+	return len(errs) == 0
+}
+
+// script runs a program without the debugger and profiling, the arguments after the file name are passed to the program.
+// It returns the exit code: 1 if the program ended with an error.
+func script(fn string, args []string, policy funcs.Policy) int {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	funcs.ScriptArgs = args
+
+	f, err := os.Open(fn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	l := lexer.New(f, fn)
+	p := parser.New(l)
+
+	c := compiler.NewCompiler()
+	compiledModule, err := c.CompileRunModule(p.ReadModule(fn))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "compilation error: %s\n", err)
+		return 1
+	}
+
+	v := vm.New(compiledModule)
+	v.DisableDebugger()
+	v.SetPolicy(policy)
+	if evaled := v.Run(); evaled.Type() == object.ERROR {
+		printError(os.Stderr, evaled.(*object.Error))
+		return 1
+	}
+	return 0
+}
+
+// runOptions are the flags of `ryan run`, the file name and the arguments after it
+type runOptions struct {
+	seed   int64
+	seeded bool
+	policy funcs.Policy
+	file   string
+	args   []string
+}
+
+// parseRun parses the arguments of `ryan run`, the flags come before the file name
+func parseRun(args []string) (runOptions, error) {
+	var opts runOptions
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		switch args[0] {
+		case "--seed":
+			if len(args) < 2 {
+				return opts, errors.New("missing value for --seed")
+			}
+			seed, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return opts, fmt.Errorf("invalid seed: %s", args[1])
+			}
+			opts.seed, opts.seeded = seed, true
+			args = args[1:]
+		case "--sandbox":
+			opts.policy.Sandbox = true
+		case "--allow-exec":
+			opts.policy.AllowExec = true
+		default:
+			return opts, fmt.Errorf("unknown flag: %s", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return opts, errors.New("missing file name")
+	}
+	opts.file, opts.args = args[0], args[1:]
+	return opts, nil
+}
+
+func printError(w io.Writer, err *object.Error) {
+	for i, e := range err.Last(4) {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		if e.Loc != nil {
+			fmt.Fprintf(w, "%s: ", e.Loc.String())
+		}
+		fmt.Fprintln(w, e.Msg)
+	}
+}
+
 func main() {
+	if len(os.Args) == 3 && os.Args[1] == "check" {
+		if !check(os.Args[2]) {
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "run" {
+		opts, err := parseRun(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if opts.seeded {
+			funcs.SeedRandom(opts.seed)
+		}
+		os.Exit(script(opts.file, opts.args, opts.policy))
+	}
 	if len(os.Args) != 2 {
-		panic("filename expected as the single argument")
+		panic("usage: ryan file.txt | ryan run [--seed N] [--sandbox [--allow-exec]] file.txt [-- args...] | ryan check file.txt")
 	}
 
 	f, err := os.Create("cpuprofile")
@@ -73,6 +198,10 @@ func main() {
 	if err = pprof.StartCPUProfile(f); err != nil {
 		panic(err)
 	}
+	funcs.Exit = func(code int) {
+		pprof.StopCPUProfile()
+		os.Exit(code)
+	}
 
 	//hf, err := os.Create("heapprofile")
 	//if err != nil {
@@ -88,12 +217,8 @@ func main() {
 	pprof.StopCPUProfile()
 
 	if evaled.Type() == object.ERROR {
-		for i, e := range evaled.(*object.Error).Last(4) {
-			if i > 0 {
-				fmt.Print("\t")
-			}
-			fmt.Printf("%s: %s\n", e.Loc.String(), e.Msg)
-		}
+		printError(os.Stdout, evaled.(*object.Error))
+		os.Exit(1)
 	} else {
 		fmt.Println(evaled.String())
 	}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"ryanlang/compiler"
+	"ryanlang/eval"
+	"ryanlang/funcs"
+	"ryanlang/lexer"
+	"ryanlang/object"
+	"ryanlang/parser"
+	"ryanlang/vm"
+	"strings"
+	"testing"
+	"time"
+)
+
+var engines = []string{"eval", "vm"}
+
+// runSource executes the program in one of the engines and returns what it ended with: the exported ok or an error
+func runSource(t *testing.T, engine, src string, stdout io.Writer) object.Object {
+	mod := parser.New(lexer.NewFromString(src)).ReadModule("test")
+	if engine == "eval" {
+		e := eval.New()
+		e.SetOutput(stdout, io.Discard)
+		ret := e.Eval(mod)
+		if object.IsError(ret) {
+			return ret
+		}
+		return exported(ret.(*object.Module).Exports)
+	}
+	compiled, err := compiler.NewCompiler().CompileRunModule(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := vm.New(compiled)
+	v.DisableDebugger()
+	v.SetOutput(stdout, io.Discard)
+	ret := v.Run()
+	if object.IsError(ret) {
+		return ret
+	}
+	return exported(ret.(*object.Struct).Fields)
+}
+
+// exported returns the value of ok, or null for programs which don't export it
+func exported(fields map[string]object.Object) object.Object {
+	if ok, found := fields["ok"]; found {
+		return ok
+	}
+	return &object.StaticNull
+}
+
+// TestScripts runs the test modules of the language features, each exports a test function returning true on success.
+// The ones depending on the arguments or the standard input can only run here, the rest is also in src/test.txt.
+func TestScripts(t *testing.T) {
+	modules := []string{
+		"tests/lang.txt",
+		"tests/defer.txt",
+		"tests/in.txt",
+		"tests/strings.txt",
+		"tests/regex.txt",
+		"tests/json.txt",
+		"tests/iter.txt",
+		"tests/fs.txt",
+		"tests/stdin.txt",
+		"tests/heap.txt",
+		"tests/grid.txt",
+		"tests/graph.txt",
+		"tests/memo.txt",
+		"tests/unicode.txt",
+		"tests/time.txt",
+		"tests/random.txt",
+		"tests/encoding.txt",
+		"tests/exec.txt",
+		"tests/const.txt",
+		"tests/comprehension.txt",
+		"tests/sort.txt",
+		"tests/format.txt",
+	}
+
+	for _, module := range modules {
+		for _, engine := range engines {
+			t.Run(module+"/"+engine, func(t *testing.T) {
+				// scripts working with files get a directory as the first argument
+				funcs.ScriptArgs = []string{t.TempDir()}
+				funcs.Stdin = bufio.NewReader(strings.NewReader("first\nsecond\nthird\nfourth\n"))
+				var out bytes.Buffer
+				src := "exports { ok; }; let ok = import(\"" + module + "\").test();"
+				ret := runSource(t, engine, src, &out)
+				if b, ok := ret.(*object.Boolean); !ok || !b.Value {
+					t.Errorf("want true, got %s, output: %s", ret.String(), out.String())
+				}
+			})
+		}
+	}
+}
+
+// TestErrors checks that both engines stop on runtime errors with the same message and output
+func TestErrors(t *testing.T) {
+	tc := []struct {
+		src string
+		err string
+		out string
+	}{
+		{
+			src: "let f = func() { defer println(\"f exits\"); return [].(1); }; let g = func() { defer println(\"g exits\"); f(); }; g();",
+			err: "index out of range: 1",
+			out: "\"f exits\"\n\"g exits\"\n",
+		},
+		{
+			src: "let strings = import(\"strings\"); strings.repeat(\"a\", -1); println(\"unreachable\");",
+			err: "cannot repeat a string a negative number of times",
+		},
+		{src: "let strings = import(\"strings\"); strings.repeat(\"ab\", 4611686018427387903); println(\"unreachable\");", err: "argument n: the string would be longer than 1073741824 bytes"},
+		{src: "let strings = import(\"strings\"); strings.repeat(\"ab\", 536870913); println(\"unreachable\");", err: "argument n: the string would be longer than 1073741824 bytes"},
+		{src: "let strings = import(\"strings\"); strings.padleft(\"a\", 9223372036854775807, \" \"); println(\"unreachable\");", err: "argument width: the string would be longer than 1073741824 bytes"},
+		{src: "let strings = import(\"strings\"); strings.padright(\"a\", 1073741824, \"é\"); println(\"unreachable\");", err: "argument width: the string would be longer than 1073741824 bytes"},
+		{
+			src: "let regex = import(\"regex\"); regex.match(\"(\", \"a\"); println(\"unreachable\");",
+			err: "invalid pattern",
+		},
+		{
+			src: "let json = import(\"json\"); json.parse(\"[1, 1.5]\"); println(\"unreachable\");",
+			err: "at offset 4: only integer numbers are supported, got: 1.5",
+		},
+		{
+			src: "let json = import(\"json\"); json.parse(\"1e100\"); println(\"unreachable\");",
+			err: "only integer numbers are supported, got: 1e100",
+		},
+		{src: "let h = heap(); pop(h); println(\"unreachable\");", err: "empty"},
+		{src: "let d = deque(); popfront(d); println(\"unreachable\");", err: "empty"},
+		{src: "let h = heap(func(x, y) => \"a\"); push(h, 1); push(h, 2); println(\"unreachable\");", err: "comparator must return a number or a bool, got: string"},
+		{src: "let grid = import(\"grid\"); grid.get(grid.new(3, 3, 0), [5, 5]); println(\"unreachable\");", err: "point is out of the grid: [5, 5]"},
+		{src: "let grid = import(\"grid\"); grid.rows(grid.new3d(2, 2, 2, 0)); println(\"unreachable\");", err: "two-dimensional grid expected"},
+		{src: "let grid = import(\"grid\"); grid.columns(grid.new3d(2, 2, 2, 0)); println(\"unreachable\");", err: "two-dimensional grid expected"},
+		{src: "let grid = import(\"grid\"); grid.new(-1, 2, 0); println(\"unreachable\");", err: "negative grid size: -1"},
+		{src: "let grid = import(\"grid\"); grid.new3d(1, 2, -3, 0); println(\"unreachable\");", err: "negative grid size: -3"},
+		{src: "let grid = import(\"grid\"); grid.new(3000000000, 3000000000, 0); println(\"unreachable\");", err: "grid size [3000000000 3000000000] has more than 134217728 cells"},
+		{src: "let grid = import(\"grid\"); grid.new(4294967296, 4294967296, 0); println(\"unreachable\");", err: "grid size [4294967296 4294967296] has more than 134217728 cells"},
+		{src: "let grid = import(\"grid\"); grid.new3d(2097152, 2097152, 2097152, 0); println(\"unreachable\");", err: "has more than 134217728 cells"},
+		{src: "let grid = import(\"grid\"); grid.new(134217728, 2, 0); println(\"unreachable\");", err: "has more than 134217728 cells"},
+		{src: "let graph = import(\"graph\"); graph.toposort(map{\"a\": [\"b\"]; \"b\": [\"a\"];}); println(\"unreachable\");", err: "the graph has a cycle"},
+		{src: "let graph = import(\"graph\"); graph.dijkstra(map{\"a\": map{\"b\": -1;}; \"b\": map{};}, \"a\"); println(\"unreachable\");", err: "negative edge cost"},
+		{src: "let graph = import(\"graph\"); graph.floyd_warshall(map{\"a\": map{\"b\": -2;}; \"b\": map{\"a\": 1;};}); println(\"unreachable\");", err: "the graph has a negative cycle"},
+		{src: "let graph = import(\"graph\"); graph.bfs(func(n) { return [].(1); }, 1); println(\"unreachable\");", err: "index out of range"},
+		{src: "let graph = import(\"graph\"); graph.astar(func(n) { return [[n + 1, 1]]; }, 1, 5, func(n) => \"far\"); println(\"unreachable\");", err: "heuristic must return a number, got: string"},
+		{src: "let graph = import(\"graph\"); graph.path(graph.bfs(map{\"a\": [];}, \"a\"), map{}); println(\"unreachable\");", err: "graph nodes must be hashable, got: map"},
+		{src: "let f = memo(func(x) => 1); f(map{}); println(\"unreachable\");", err: "hashable"},
+		{src: "memo(5); println(\"unreachable\");", err: "argument f"},
+		{src: "cache_clear(func(x) => x); println(\"unreachable\");", err: "memoized function"},
+		{src: "\"すし\".(2); println(\"unreachable\");", err: "index out of range"},
+		{src: "ord(\"ab\"); println(\"unreachable\");", err: "a single character expected"},
+		{src: "chr(-1); println(\"unreachable\");", err: "not a valid code point: -1"},
+		{src: "let time = import(\"time\"); time.duration(\"soon\"); println(\"unreachable\");", err: "invalid duration"},
+		{src: "let time = import(\"time\"); time.parse(\"2022-13-01\", \"date\"); println(\"unreachable\");", err: "month out of range"},
+		{src: "let strings = import(\"strings\"); strings.trim(5); println(\"unreachable\");", err: "argument s"},
+		{src: "let regex = import(\"regex\"); regex.find(\"[\", \"a\"); println(\"unreachable\");", err: "invalid pattern"},
+		{src: "let random = import(\"random\"); random.int(5, 1); println(\"unreachable\");", err: "empty interval: 5 to 1"},
+		{src: "let random = import(\"random\"); random.choice([]); println(\"unreachable\");", err: "cannot choose from an empty collection"},
+		{src: "let random = import(\"random\"); random.sample([1, 2], 3); println(\"unreachable\");", err: "cannot sample 3 items out of 2"},
+		{src: "let hex = import(\"hex\"); hex.decode(\"zz\"); println(\"unreachable\");", err: "invalid byte"},
+		{src: "let base64 = import(\"base64\"); base64.decode(\"a\"); println(\"unreachable\");", err: "illegal base64 data"},
+		{src: "exec(\"ryan-missing-command\", []); println(\"unreachable\");", err: "exec ryan-missing-command: exec: \"ryan-missing-command\": executable file not found"},
+		{src: "let a = freeze([1, 2]); append(a, 3); println(\"unreachable\");", err: "cannot append to a frozen array"},
+		{src: "let m = freeze(map{\"k\": 1;}); delete(m, \"k\"); println(\"unreachable\");", err: "cannot delete from a frozen map"},
+		{src: "let a = freeze([1, 2]); a.(0) = 3; println(\"unreachable\");", err: "cannot assign to a field of a frozen array"},
+		{src: "let m = freeze(map{\"k\": 1;}); m.(\"k\") = 2; println(\"unreachable\");", err: "cannot assign to a field of a frozen map"},
+		{src: "let s = freeze(struct{x: 1;}); s.x = 2; println(\"unreachable\");", err: "cannot assign to a field of a frozen struct"},
+		{src: "freeze(5); println(\"unreachable\");", err: "array, map or struct expected, got: number"},
+		{src: "sort([2, 1], func(a, b) { return [].(1); }); println(\"unreachable\");", err: "index out of range: 1"},
+		{src: "sort([2, 1], func(a, b) { return \"less\"; }); println(\"unreachable\");", err: "sort: comparator must return a number or a bool, got: string"},
+		{src: "sort([2, \"a\"]); println(\"unreachable\");", err: "sort: don't know how to compare types"},
+		{src: "sortby([2, 1], func(x) { return [].(1); }); println(\"unreachable\");", err: "index out of range: 1"},
+		{src: "sortby([2, 1], func(x) { return x == 1; }); println(\"unreachable\");", err: "sortby: don't know how to compare types: boolean, boolean"},
+		{src: "let iter = import(\"iter\"); iter.count(iter.range(-9223372036854775807, 9223372036854775807)); println(\"unreachable\");", err: "too many items in range(-9223372036854775807, 9223372036854775807)"},
+		{src: "let iter = import(\"iter\"); iter.range(9223372036854775807, -2, -1); println(\"unreachable\");", err: "too many items in range(9223372036854775807, -2, -1)"},
+		{src: "format(\"{:999999999999}\", 1); println(\"unreachable\");", err: "format width is larger than 1048576: 999999999999"},
+		{src: "format(\"{:>99999999999999999999999}\", 1); println(\"unreachable\");", err: "format width is larger than 1048576"},
+		{src: "format(\"{:z}\", 1); println(\"unreachable\");", err: "unknown format type: z"},
+		{src: "format(\"{:5dd}\", 1); println(\"unreachable\");", err: "invalid format spec: 5dd"},
+		{src: "format(\"{:x}\", \"a\"); println(\"unreachable\");", err: "format type x requires a number, got: string"},
+		{src: "format(\"{2}\", 1); println(\"unreachable\");", err: "placeholder {2} refers to argument 2, but there are 1"},
+		{src: "format(\"{} {\", 1); println(\"unreachable\");", err: "unclosed { in the format string"},
+		{src: "format(\"}\"); println(\"unreachable\");", err: "single } in the format string"},
+		{src: "format(\"{}\", 1, 2); println(\"unreachable\");", err: "argument 1 is not used in the format string"},
+		{
+			src: "for x in 5 { println(x); };",
+			err: "cannot iterate over type number",
+		},
+		{src: "let m = import(\"math\"); m.pow(2, 100); println(\"unreachable\");", err: "integer overflow"},
+		{src: "let m = import(\"math\"); m.pow(2, -1); println(\"unreachable\");", err: "negative exponent"},
+		{src: "let m = import(\"math\"); m.ipow(2, -1); println(\"unreachable\");", err: "negative exponent"},
+		{src: "let m = import(\"math\"); m.lcm(9223372036854775807, 2); println(\"unreachable\");", err: "integer overflow"},
+		{src: "let m = import(\"math\"); m.sqrt(2); println(\"unreachable\");", err: "not a perfect square"},
+		{src: "let m = import(\"math\"); m.sqrt(-4); println(\"unreachable\");", err: "square root of a negative number"},
+		{src: "let m = import(\"math\"); m.isqrt(-4); println(\"unreachable\");", err: "square root of a negative number"},
+		{src: "let m = import(\"math\"); m.min([]); println(\"unreachable\");", err: "min of an empty array"},
+		{src: "let m = import(\"math\"); m.max([]); println(\"unreachable\");", err: "max of an empty array"},
+	}
+
+	for _, tt := range tc {
+		for _, engine := range engines {
+			t.Run(engine+"/"+tt.src, func(t *testing.T) {
+				var out bytes.Buffer
+				ret := runSource(t, engine, tt.src, &out)
+				if !object.IsError(ret) {
+					t.Fatalf("want error %q, got %s", tt.err, ret.String())
+				}
+				if !strings.Contains(ret.String(), tt.err) {
+					t.Errorf("want error %q, got %s", tt.err, ret.String())
+				}
+				if out.String() != tt.out {
+					t.Errorf("want output %q, got %q", tt.out, out.String())
+				}
+			})
+		}
+	}
+}
+
+// TestConst checks that constants cannot be reassigned: the compiler rejects the program, the evaluator fails when it
+// gets to the assignment
+func TestConst(t *testing.T) {
+	tc := []struct {
+		src string
+		out string // printed by the evaluator before the assignment
+	}{
+		{src: "const c = 1; println(\"before\"); c = 2;", out: "\"before\"\n"},
+		{src: "const c = 1; println(\"before\"); c++;", out: "\"before\"\n"},
+		{src: "const c = 1; let d = 0; println(\"before\"); c, d = 2, 3;", out: "\"before\"\n"},
+		{src: "const c = 1; let f = func { c = 2; }; println(\"before\"); f();", out: "\"before\"\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run("eval/"+tt.src, func(t *testing.T) {
+			var out bytes.Buffer
+			ret := runSource(t, "eval", tt.src, &out)
+			if !object.IsError(ret) || !strings.Contains(ret.String(), "cannot assign to a constant: c") {
+				t.Errorf("want a constant error, got %s", ret.String())
+			}
+			if out.String() != tt.out {
+				t.Errorf("want output %q, got %q", tt.out, out.String())
+			}
+		})
+		t.Run("vm/"+tt.src, func(t *testing.T) {
+			mod := parser.New(lexer.NewFromString(tt.src)).ReadModule("test")
+			_, err := compiler.NewCompiler().CompileRunModule(mod)
+			if err == nil || !strings.Contains(err.Error(), "cannot assign to a constant: c") {
+				t.Errorf("want a constant error, got %v", err)
+			}
+		})
+	}
+}
+
+// exitCalled stops a program calling exit() in tests, where it must not end the process
+type exitCalled int
+
+// TestScript checks the arguments, the environment and the exit codes of programs run by `ryan run`
+func TestScript(t *testing.T) {
+	t.Setenv("RYAN_TEST", "value")
+	tc := []struct {
+		src    string
+		args   []string
+		policy funcs.Policy
+		code   int
+	}{
+		{src: "let x = 1;", code: 0},
+		{src: "exit(3); println(\"unreachable\");", code: 3},
+		{src: "let a = args(); if len(a) != 2 || a.(0) != \"x\" || a.(1) != \"-- y\" { exit(4); };", args: []string{"--", "x", "-- y"}, code: 0},
+		{src: "if len(args()) != 0 { exit(4); };", code: 0},
+		{src: "if env(\"RYAN_TEST\") != \"value\" || env(\"RYAN_TEST_MISSING\") != null { exit(5); };", code: 0},
+		{src: "[].(1);", code: 1},
+		{src: "exec(\"true\", []);", policy: funcs.Policy{Sandbox: true}, code: 1},
+		{src: "if exec(\"true\", []).code != 0 { exit(4); };", policy: funcs.Policy{Sandbox: true, AllowExec: true}, code: 0},
+	}
+
+	defer func(exit func(int)) { funcs.Exit = exit }(funcs.Exit)
+	funcs.Exit = func(code int) { panic(exitCalled(code)) }
+
+	for _, tt := range tc {
+		t.Run(tt.src, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "test.txt")
+			if err := os.WriteFile(fn, []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			code := func() (code int) {
+				defer func() {
+					if r := recover(); r != nil {
+						exit, ok := r.(exitCalled)
+						if !ok {
+							panic(r)
+						}
+						code = int(exit)
+					}
+				}()
+				return script(fn, tt.args, tt.policy)
+			}()
+			if code != tt.code {
+				t.Errorf("want exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+// TestParseRun checks the flags of `ryan run`
+func TestParseRun(t *testing.T) {
+	tc := []struct {
+		args []string
+		opts runOptions
+		err  string
+	}{
+		{args: []string{"a.txt"}, opts: runOptions{file: "a.txt", args: []string{}}},
+		{args: []string{"--seed", "7", "--sandbox", "a.txt", "--", "x"}, opts: runOptions{seed: 7, seeded: true, policy: funcs.Policy{Sandbox: true}, file: "a.txt", args: []string{"--", "x"}}},
+		{args: []string{"--sandbox", "--allow-exec", "a.txt"}, opts: runOptions{policy: funcs.Policy{Sandbox: true, AllowExec: true}, file: "a.txt", args: []string{}}},
+		{args: []string{}, err: "missing file name"},
+		{args: []string{"--sandbox"}, err: "missing file name"},
+		{args: []string{"--seed"}, err: "missing value for --seed"},
+		{args: []string{"--seed", "x", "a.txt"}, err: "invalid seed: x"},
+		{args: []string{"--verbose", "a.txt"}, err: "unknown flag: --verbose"},
+	}
+
+	for _, tt := range tc {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			opts, err := parseRun(tt.args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("want error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(opts, tt.opts) {
+				t.Errorf("want %+v, got %+v", tt.opts, opts)
+			}
+		})
+	}
+}
+
+// TestExecTimeout checks that a timeout ends the command along with the processes it started
+func TestExecTimeout(t *testing.T) {
+	src := "exec(\"sh\", [\"-c\", \"sleep 5; echo done\"], null, 200); println(\"unreachable\");"
+	for _, engine := range engines {
+		t.Run(engine, func(t *testing.T) {
+			start := time.Now()
+			var stdout bytes.Buffer
+			ret := runSource(t, engine, src, &stdout)
+			if !object.IsError(ret) || !strings.Contains(ret.String(), "timed out after 200ms") {
+				t.Errorf("want a timeout error, got %s", ret.String())
+			}
+			if stdout.Len() > 0 {
+				t.Errorf("want no output, got %q", stdout.String())
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("want the command killed after the timeout, it took %s", elapsed)
+			}
+		})
+	}
+}
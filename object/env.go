@@ -2,6 +2,7 @@ package object
 
 type Environment struct {
 	storage map[string]Object
+	consts  map[string]bool
 	parent  *Environment
 }
 
@@ -31,6 +32,26 @@ func (e *Environment) GetFromCurrent(key string) (Object, bool) {
 func (e *Environment) Set(key string, value Object) {
 	e.storage[key] = value
 }
+func (e *Environment) SetConst(key string, value Object) {
+	if e.consts == nil {
+		e.consts = map[string]bool{}
+	}
+	e.storage[key] = value
+	e.consts[key] = true
+}
+
+// IsConst reports whether the closest declaration of the key was a `const` one
+func (e *Environment) IsConst(key string) bool {
+	environ := e
+	for environ != nil {
+		if _, ok := environ.storage[key]; ok {
+			return environ.consts[key]
+		}
+
+		environ = environ.parent
+	}
+	return false
+}
 func (e *Environment) Replace(key string, value Object) bool {
 	environ := e
 	for environ != nil {
@@ -0,0 +1,76 @@
+package object
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+)
+
+// Memo caches the results of a function by its arguments, it is attached to the functions wrapped with memo()
+type Memo struct {
+	Limit  int // maximum number of cached results, the least recently used ones are evicted; 0 means no limit
+	Hits   int
+	Misses int
+	items  map[string]*list.Element
+	order  *list.List // of *memoEntry, the most recently used first
+}
+
+type memoEntry struct {
+	key   string
+	value Object
+}
+
+func NewMemo(limit int) *Memo {
+	return &Memo{Limit: limit, items: map[string]*list.Element{}, order: list.New()}
+}
+
+func (m *Memo) String() string {
+	return fmt.Sprintf("memo: %d hits, %d misses, %d cached", m.Hits, m.Misses, m.order.Len())
+}
+
+// Key combines hashes of the arguments, which must all be hashable
+func (m *Memo) Key(args []Object) (string, error) {
+	hashes := make([]string, len(args))
+	for i, arg := range args {
+		h, ok := arg.(Hashable)
+		if !ok {
+			return "", fmt.Errorf("arguments of a memoized function must be hashable, got: %s", arg.Type().String())
+		}
+		hashes[i] = h.Hash()
+	}
+	return strings.Join(hashes, ", "), nil
+}
+
+// Get returns the cached result, counting hits and misses
+func (m *Memo) Get(key string) (Object, bool) {
+	e, ok := m.items[key]
+	if !ok {
+		m.Misses++
+		return nil, false
+	}
+	m.Hits++
+	m.order.MoveToFront(e)
+	return e.Value.(*memoEntry).value, true
+}
+
+func (m *Memo) Put(key string, value Object) {
+	if e, ok := m.items[key]; ok {
+		// a recursive call could have stored the result already
+		e.Value.(*memoEntry).value = value
+		m.order.MoveToFront(e)
+		return
+	}
+	m.items[key] = m.order.PushFront(&memoEntry{key: key, value: value})
+	if m.Limit > 0 && m.order.Len() > m.Limit {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoEntry).key)
+	}
+}
+
+// Clear drops the cached results and resets the counters
+func (m *Memo) Clear() {
+	m.Hits, m.Misses = 0, 0
+	m.items = map[string]*list.Element{}
+	m.order.Init()
+}
@@ -2,6 +2,7 @@ package object
 
 import (
 	"fmt"
+	"math"
 	"ryanlang/ast"
 	"ryanlang/lexer"
 	"strconv"
@@ -29,6 +30,12 @@ const (
 	TUPLE
 	CLOSURE
 	CODE // uncallable code that must be converted to closure in order to be called
+	NATIVE
+	RANGE
+	HEAP
+	DEQUE
+	GRID
+	ITERATOR
 )
 
 func (t Type) String() string {
@@ -63,6 +70,18 @@ func (t Type) String() string {
 		return "closure"
 	case CODE:
 		return "code"
+	case NATIVE:
+		return "native"
+	case RANGE:
+		return "range"
+	case HEAP:
+		return "heap"
+	case DEQUE:
+		return "deque"
+	case GRID:
+		return "grid"
+	case ITERATOR:
+		return "iterator"
 	}
 
 	panic("unknown object type: " + strconv.Itoa(int(t)))
@@ -153,9 +172,13 @@ func (s String) Type() Type {
 type Function struct {
 	Node ast.FuncExpression
 	Env  *Environment
+	Memo *Memo // set if the results are cached
 }
 
 func (f Function) String() string {
+	if f.Memo != nil {
+		return f.Node.String() + " (" + f.Memo.String() + ")"
+	}
 	return f.Node.String()
 }
 
@@ -231,6 +254,7 @@ func StaticBool(b bool) Object {
 
 type Struct struct {
 	Fields map[string]Object
+	Frozen bool
 }
 
 func (s Struct) String() string {
@@ -251,6 +275,7 @@ type MapItem struct {
 }
 type Map struct {
 	Fields map[string]MapItem
+	Frozen bool
 }
 
 func (m Map) String() string {
@@ -266,7 +291,8 @@ func (m Map) Type() Type {
 }
 
 type Array struct {
-	Items []Object
+	Items  []Object
+	Frozen bool
 }
 
 func (a Array) Hash() string {
@@ -348,11 +374,14 @@ type Closure struct {
 	Code                *Code
 	Foreigns            []*Object
 	BuiltinFunctionName string
+	Memo                *Memo // set if the results are cached
 }
 
 func (c Closure) String() string {
 	if c.BuiltinFunctionName != "" {
 		return fmt.Sprintf("(built-in: %s)", c.BuiltinFunctionName)
+	} else if c.Memo != nil {
+		return fmt.Sprintf("(closure, %s)", c.Memo.String())
 	} else {
 		return fmt.Sprintf("(closure)")
 	}
@@ -362,6 +391,283 @@ func (c Closure) Type() Type {
 	return CLOSURE
 }
 
+// Native wraps a Go value, which ryanlang code cannot inspect, but can pass around to the built-in functions
+type Native struct {
+	Name  string // kind of the wrapped value, e.g. "regex"
+	Value interface{}
+}
+
+func (n Native) String() string {
+	return fmt.Sprintf("%s(%v)", n.Name, n.Value)
+}
+
+func (n Native) Type() Type {
+	return NATIVE
+}
+
+// Range is a lazy sequence of numbers from From up to, but not including, To
+type Range struct {
+	From int
+	To   int
+	Step int // never zero
+}
+
+func (r Range) String() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.From, r.To)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.From, r.To, r.Step)
+}
+
+func (r Range) Type() Type {
+	return RANGE
+}
+
+// count returns the number of items in the range, computed unsigned as the distance of the ends can overflow an int
+func (r Range) count() uint64 {
+	var dist, step uint64
+	switch {
+	case r.Step > 0 && r.To > r.From:
+		dist, step = uint64(r.To)-uint64(r.From), uint64(r.Step)
+	case r.Step < 0 && r.To < r.From:
+		dist, step = uint64(r.From)-uint64(r.To), -uint64(r.Step)
+	default:
+		return 0
+	}
+	n := dist / step
+	if dist%step != 0 {
+		n++
+	}
+	return n
+}
+
+// Fits reports whether the number of items fits in an int, Len is only valid for such ranges
+func (r Range) Fits() bool {
+	return r.count() <= math.MaxInt
+}
+
+// Len returns the number of items in the range
+func (r Range) Len() int {
+	return int(r.count())
+}
+
+// Index returns the position of v in the range, or false if it isn't one of its items
+func (r Range) Index(v int) (int, bool) {
+	var dist, step uint64
+	switch {
+	case r.Step > 0 && v >= r.From:
+		dist, step = uint64(v)-uint64(r.From), uint64(r.Step)
+	case r.Step < 0 && v <= r.From:
+		dist, step = uint64(r.From)-uint64(v), -uint64(r.Step)
+	default:
+		return 0, false
+	}
+	if dist%step != 0 || dist/step >= r.count() {
+		return 0, false
+	}
+	return int(dist / step), true
+}
+
+// At returns the i-th item of the range, the arithmetic wraps around so that it cannot overflow before getting to
+// the item, which is always between From and To
+func (r Range) At(i int) int {
+	return int(uint64(r.From) + uint64(i)*uint64(r.Step))
+}
+
+// Iterator is a lazy sequence of index, value pairs, it's how for loops go over collections without copying them
+type Iterator struct {
+	// Next returns the next index and value, a nil value at the end or an *Error if the iteration failed
+	Next func() (index Object, value Object)
+}
+
+func (it Iterator) String() string {
+	return "iterator"
+}
+
+func (it Iterator) Type() Type {
+	return ITERATOR
+}
+
+// HeapItem is a value stored in a Heap along with the key it is ordered by
+type HeapItem struct {
+	Value Object
+	Key   Object // nil if the value itself is the key
+}
+
+// OrderKey returns the object the item is ordered by
+func (i HeapItem) OrderKey() Object {
+	if i.Key != nil {
+		return i.Key
+	}
+	return i.Value
+}
+
+// Heap is a binary min-heap, the ordering is maintained by the built-in functions since the comparator may be
+// ryanlang code
+type Heap struct {
+	Items []HeapItem
+	Cmp   Object // comparator function, null for the default ordering
+}
+
+func (h Heap) String() string {
+	strs := []string{}
+	for _, item := range h.Items {
+		if item.Key != nil {
+			strs = append(strs, fmt.Sprintf("%s: %s", item.Key.String(), item.Value.String()))
+		} else {
+			strs = append(strs, item.Value.String())
+		}
+	}
+	return fmt.Sprintf("heap(%s)", strings.Join(strs, ", "))
+}
+
+func (h Heap) Type() Type {
+	return HEAP
+}
+
+// Values returns the values of the heap in the storage order, the first one is the smallest
+func (h Heap) Values() []Object {
+	ret := make([]Object, len(h.Items))
+	for i, item := range h.Items {
+		ret[i] = item.Value
+	}
+	return ret
+}
+
+// Deque is a double-ended queue backed by a ring buffer
+type Deque struct {
+	items []Object
+	head  int
+	size  int
+}
+
+func (d Deque) String() string {
+	strs := []string{}
+	for _, item := range d.Values() {
+		strs = append(strs, item.String())
+	}
+	return fmt.Sprintf("deque(%s)", strings.Join(strs, ", "))
+}
+
+func (d Deque) Type() Type {
+	return DEQUE
+}
+
+func (d Deque) Len() int {
+	return d.size
+}
+
+// At returns the i-th item counting from the front
+func (d Deque) At(i int) Object {
+	return d.items[(d.head+i)%len(d.items)]
+}
+
+// Values returns the items from the front to the back
+func (d Deque) Values() []Object {
+	ret := make([]Object, d.size)
+	for i := range ret {
+		ret[i] = d.At(i)
+	}
+	return ret
+}
+
+func (d *Deque) grow() {
+	if d.size < len(d.items) {
+		return
+	}
+	items := make([]Object, 2*len(d.items)+1)
+	copy(items, d.Values())
+	d.items = items
+	d.head = 0
+}
+
+func (d *Deque) PushBack(item Object) {
+	d.grow()
+	d.items[(d.head+d.size)%len(d.items)] = item
+	d.size++
+}
+
+func (d *Deque) PushFront(item Object) {
+	d.grow()
+	d.head = (d.head + len(d.items) - 1) % len(d.items)
+	d.items[d.head] = item
+	d.size++
+}
+
+// PopBack removes and returns the last item, the deque must not be empty
+func (d *Deque) PopBack() Object {
+	d.size--
+	i := (d.head + d.size) % len(d.items)
+	item := d.items[i]
+	d.items[i] = nil
+	return item
+}
+
+// PopFront removes and returns the first item, the deque must not be empty
+func (d *Deque) PopFront() Object {
+	item := d.items[d.head]
+	d.items[d.head] = nil
+	d.head = (d.head + 1) % len(d.items)
+	d.size--
+	return item
+}
+
+// Grid is a two or three-dimensional array of cells stored in a flat slice, x changes the fastest
+type Grid struct {
+	Size  []int // width, height and, for three-dimensional grids, depth
+	Cells []Object
+}
+
+func (g Grid) String() string {
+	var rows func(offset int, dim int) string
+	rows = func(offset int, dim int) string {
+		strs := []string{}
+		if dim == 0 {
+			for _, cell := range g.Cells[offset : offset+g.Size[0]] {
+				strs = append(strs, cell.String())
+			}
+		} else {
+			stride := 1
+			for _, n := range g.Size[:dim] {
+				stride *= n
+			}
+			for i := 0; i < g.Size[dim]; i++ {
+				strs = append(strs, rows(offset+i*stride, dim-1))
+			}
+		}
+		return "[" + strings.Join(strs, ", ") + "]"
+	}
+	return fmt.Sprintf("grid%s", rows(0, len(g.Size)-1))
+}
+
+func (g Grid) Type() Type {
+	return GRID
+}
+
+// Index converts coordinates of a cell into its index in Cells, ok is false if the cell is out of bounds
+func (g Grid) Index(p []int) (i int, ok bool) {
+	if len(p) != len(g.Size) {
+		return 0, false
+	}
+	for d := len(p) - 1; d >= 0; d-- {
+		if p[d] < 0 || p[d] >= g.Size[d] {
+			return 0, false
+		}
+		i = i*g.Size[d] + p[d]
+	}
+	return i, true
+}
+
+// Point converts an index in Cells into coordinates of the cell
+func (g Grid) Point(i int) []int {
+	p := make([]int, len(g.Size))
+	for d, n := range g.Size {
+		p[d] = i % n
+		i /= n
+	}
+	return p
+}
+
 type CodeReturnScope int
 
 const (
@@ -27,6 +27,19 @@ func WrapError(e Object, msg string, loc *lexer.Location) *Error {
 func IsError(obj Object) bool {
 	return obj.Type() == ERROR
 }
+
+// IsFrozen reports whether the object was made immutable with freeze()
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Frozen
+	case *Map:
+		return obj.Frozen
+	case *Struct:
+		return obj.Frozen
+	}
+	return false
+}
 func IsHashable(obj Object) bool {
 	_, ok := obj.(Hashable)
 	return ok
@@ -131,6 +131,13 @@ func (p *Parser) parseMod(left ast.Expression) ast.Expression {
 		Right: p.readExpression(precedenceMultDiv),
 	}
 }
+func (p *Parser) parsePow(left ast.Expression) ast.Expression {
+	p.consume(lexer.TokenTypePow)
+	return ast.PowExpression{
+		Left:  left,
+		Right: p.readExpression(precedencePow - 1), // right-associative: 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	}
+}
 func (p *Parser) parseLogicalAnd(left ast.Expression) ast.Expression {
 	p.consume(lexer.TokenTypeLogicalAnd)
 	return ast.LogicalAndExpression{
@@ -162,6 +169,23 @@ func (p *Parser) parseDiv(left ast.Expression) ast.Expression {
 	}
 }
 
+func (p *Parser) parseIn(left ast.Expression) ast.Expression {
+	p.consume(lexer.TokenTypeIn)
+	return ast.InExpression{
+		Left:  left,
+		Right: p.readExpression(precedenceGtLt),
+	}
+}
+func (p *Parser) parseNotIn(left ast.Expression) ast.Expression {
+	p.consume(lexer.TokenTypeNot)
+	p.consume(lexer.TokenTypeIn)
+	return ast.NegationExpression{
+		Expr: ast.InExpression{
+			Left:  left,
+			Right: p.readExpression(precedenceGtLt),
+		},
+	}
+}
 func (p *Parser) parseGt(left ast.Expression) ast.Expression {
 	p.consume(lexer.TokenTypeGt)
 	return ast.GtExpression{
@@ -279,6 +303,13 @@ func (p *Parser) parseAssign(left ast.Expression) ast.Expression {
 func (p *Parser) parseFieldAccess(left ast.Expression) ast.Expression {
 	p.consume(lexer.TokenTypeDot)
 
+	if p.cur.IsKeyword() { // keywords can be used as field names, e.g. iter.map
+		tok := p.consume(p.cur.Kind)
+		return ast.FieldAccessExpression{
+			Left:  left,
+			Right: ast.String{Value: tok.Literal, Loc: tok.Location},
+		}
+	}
 	right := p.readExpression(precedenceFieldAccess)
 	switch right.(type) {
 	case ast.Identifier:
@@ -22,12 +22,14 @@ func New(l *lexer.Lexer) *Parser {
 		lexer.TokenTypeNumber:         p.parseNumber,
 		lexer.TokenTypeString:         p.parseString,
 		lexer.TokenTypeLet:            p.parseLet,
+		lexer.TokenTypeConst:          p.parseLet,
 		lexer.TokenTypeIdentifier:     p.parseIdentifier,
 		lexer.TokenTypeImport:         p.parseImport,
 		lexer.TokenTypeIf:             p.parseIf,
 		lexer.TokenTypeLBracket:       p.parseGroup,
 		lexer.TokenTypeFunc:           p.parseFunc,
 		lexer.TokenTypeReturn:         p.parseReturn,
+		lexer.TokenTypeDefer:          p.parseDefer,
 		lexer.TokenTypeContinue:       p.parseContinue,
 		lexer.TokenTypeBreak:          p.parseBreak,
 		lexer.TokenTypeStruct:         p.parseStruct,
@@ -41,6 +43,8 @@ func New(l *lexer.Lexer) *Parser {
 	}
 
 	p.infixFunctions = map[lexer.TokenKind]infixParseFunction{
+		lexer.TokenTypeIn:          p.parseIn,
+		lexer.TokenTypeNot:         p.parseNotIn,
 		lexer.TokenTypePlus:        p.parsePlus,
 		lexer.TokenTypePlusAssign:  p.parsePlusAssign,
 		lexer.TokenTypePlusPlus:    p.parsePlusPlus,
@@ -61,6 +65,7 @@ func New(l *lexer.Lexer) *Parser {
 		lexer.TokenTypeAssign:      p.parseAssign,
 		lexer.TokenTypeDot:         p.parseFieldAccess,
 		lexer.TokenTypeMod:         p.parseMod,
+		lexer.TokenTypePow:         p.parsePow,
 		lexer.TokenTypeComma:       p.parseTuple,
 	}
 	return p
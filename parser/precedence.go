@@ -15,6 +15,7 @@ const (
 	precedenceBang
 	precedenceMultDiv
 	precedencePrefixMinus
+	precedencePow
 	precedenceIncrDecr
 	precedenceCall
 	precedenceFieldAccess
@@ -48,9 +49,13 @@ var precedences = map[lexer.TokenKind]int{
 	lexer.TokenTypeLogicalAnd:     precedenceLogicalAnd,
 	lexer.TokenTypeLogicalOr:      precedenceLogicalOr,
 	lexer.TokenTypeMod:            precedenceMultDiv,
+	lexer.TokenTypePow:            precedencePow,
 	lexer.TokenTypePlusAssign:     precedenceAssign,
 	lexer.TokenTypeMinusAssign:    precedenceAssign,
 	lexer.TokenTypePlusPlus:       precedenceIncrDecr,
 	lexer.TokenTypeMinusMinus:     precedenceIncrDecr,
 	lexer.TokenTypeColon:          precedenceLowest,
+	lexer.TokenTypeIf:             precedenceLowest,
+	lexer.TokenTypeIn:             precedenceGtLt,
+	lexer.TokenTypeNot:            precedenceGtLt,
 }
@@ -33,6 +33,29 @@ func (p *Parser) parseIdentifier() ast.Expression {
 		Name: p.consume(lexer.TokenTypeIdentifier).Literal,
 	}
 }
+func (p *Parser) parseDeclaredIdentifier() ast.Identifier {
+	id := p.parseIdentifier().(ast.Identifier)
+	if p.cur.Kind == lexer.TokenTypeColon {
+		p.consume(lexer.TokenTypeColon)
+		id.Type = p.readTypeAnnotation()
+	}
+	return id
+}
+func (p *Parser) readTypeAnnotation() *ast.TypeAnnotation {
+	loc := p.cur.Location
+	switch p.cur.Kind {
+	case lexer.TokenTypeLSquareBracket: // [number]
+		p.consume(lexer.TokenTypeLSquareBracket)
+		elem := p.readTypeAnnotation()
+		p.consume(lexer.TokenTypeRSquareBracket)
+		return &ast.TypeAnnotation{Elem: elem, Loc: loc}
+	case lexer.TokenTypeIdentifier, lexer.TokenTypeFunc, lexer.TokenTypeMap, lexer.TokenTypeStruct:
+		// type names are validated by the type checker, not here
+		return &ast.TypeAnnotation{Name: p.consume(p.cur.Kind).Literal, Loc: loc}
+	default:
+		panic(p.location() + ": type expected, got: " + p.cur.Kind.String())
+	}
+}
 func (p *Parser) parseImport() ast.Expression {
 	loc := p.cur.Location
 	p.consume(lexer.TokenTypeImport)
@@ -46,18 +69,20 @@ func (p *Parser) parseImport() ast.Expression {
 }
 func (p *Parser) parseLet() ast.Expression {
 	loc := p.cur.Location
-	p.consume(lexer.TokenTypeLet)
+	isConst := p.cur.Kind == lexer.TokenTypeConst
+	p.consume(p.cur.Kind) // either `let` or `const`
 	ids := []ast.Identifier{}
-	ids = append(ids, p.parseIdentifier().(ast.Identifier))
+	ids = append(ids, p.parseDeclaredIdentifier())
 	for p.cur.Kind == lexer.TokenTypeComma {
 		p.consume(lexer.TokenTypeComma)
-		ids = append(ids, p.parseIdentifier().(ast.Identifier))
+		ids = append(ids, p.parseDeclaredIdentifier())
 	}
 	p.consume(lexer.TokenTypeAssign)
 	initialization := p.readExpression(precedenceAssign)
 	return ast.LetExpression{
 		Identifiers:    ids,
 		Initialization: initialization,
+		Const:          isConst,
 		Loc:            loc,
 	}
 }
@@ -117,6 +142,18 @@ func (p *Parser) readArrowExpression() ast.Expression {
 	p.consume(lexer.TokenTypeArrow)
 	return ast.ArrowExpression{Expr: p.readExpression(precedenceLowest), Loc: loc}
 }
+
+// readLoopArrowExpression reads the arrow body of a loop, which is either `=> value` or `=> key: value`
+func (p *Parser) readLoopArrowExpression() ast.Expression {
+	loc := p.cur.Location
+	p.consume(lexer.TokenTypeArrow)
+	expr := p.readExpression(precedenceLowest)
+	if p.cur.Kind == lexer.TokenTypeColon {
+		p.consume(lexer.TokenTypeColon)
+		return ast.ArrowExpression{Key: expr, Expr: p.readExpression(precedenceLowest), Loc: loc}
+	}
+	return ast.ArrowExpression{Expr: expr, Loc: loc}
+}
 func (p *Parser) parseFunc() ast.Expression {
 	result := ast.FuncExpression{
 		Loc: p.cur.Location,
@@ -128,7 +165,7 @@ func (p *Parser) parseFunc() ast.Expression {
 		p.consume(lexer.TokenTypeLBracket)
 		expectingArgument := false
 		for p.cur.Kind != lexer.TokenTypeRBracket {
-			result.Arguments = append(result.Arguments, p.parseIdentifier().(ast.Identifier))
+			result.Arguments = append(result.Arguments, p.parseDeclaredIdentifier())
 			expectingArgument = false
 			if p.cur.Kind == lexer.TokenTypeComma {
 				p.consume(lexer.TokenTypeComma)
@@ -141,6 +178,11 @@ func (p *Parser) parseFunc() ast.Expression {
 		p.consume(lexer.TokenTypeRBracket)
 	}
 
+	if p.cur.Kind == lexer.TokenTypeColon { // return type: func(a: number): number { ... }
+		p.consume(lexer.TokenTypeColon)
+		result.ReturnType = p.readTypeAnnotation()
+	}
+
 	if p.cur.Kind == lexer.TokenTypeLBrace {
 		result.Body = p.readBlockExpression()
 	} else if p.cur.Kind == lexer.TokenTypeArrow {
@@ -157,6 +199,14 @@ func (p *Parser) parseReturn() ast.Expression {
 		Expr: p.readExpression(precedenceLowest),
 	}
 }
+func (p *Parser) parseDefer() ast.Expression {
+	loc := p.cur.Location
+	p.consume(lexer.TokenTypeDefer)
+	return ast.DeferExpression{
+		Expr: p.readExpression(precedenceLowest),
+		Loc:  loc,
+	}
+}
 func (p *Parser) parseContinue() ast.Expression {
 	p.consume(lexer.TokenTypeContinue)
 	return ast.ContinueExpression{}
@@ -235,7 +285,7 @@ func (p *Parser) parseWhile() ast.Expression {
 	if p.cur.Kind == lexer.TokenTypeLBrace {
 		result.Body = p.readBlockExpression()
 	} else if p.cur.Kind == lexer.TokenTypeArrow {
-		result.Body = p.readArrowExpression()
+		result.Body = p.readLoopArrowExpression()
 	} else {
 		panic("missing while loop body")
 	}
@@ -245,6 +295,12 @@ func (p *Parser) parseWhile() ast.Expression {
 func (p *Parser) parseFor() ast.Expression {
 	loc := p.cur.Location
 	p.consume(lexer.TokenTypeFor)
+	return p.readForGenerator(loc)
+}
+
+// readForGenerator reads `[index,] value in range [if filter]` followed by either the loop body
+// or, after a comma, the next generator of a comprehension
+func (p *Parser) readForGenerator(loc *lexer.Location) ast.ForExpression {
 	result := ast.ForExpression{
 		Loc: loc,
 	}
@@ -259,12 +315,23 @@ func (p *Parser) parseFor() ast.Expression {
 
 	p.consume(lexer.TokenTypeIn)
 
-	result.Range = p.readExpression(precedenceLowest)
+	result.Range = p.readExpression(precedenceComma)
 
-	if p.cur.Kind == lexer.TokenTypeLBrace {
+	if p.cur.Kind == lexer.TokenTypeIf {
+		p.consume(lexer.TokenTypeIf)
+		result.Filter = p.readExpression(precedenceComma)
+	}
+
+	if p.cur.Kind == lexer.TokenTypeComma {
+		p.consume(lexer.TokenTypeComma)
+		result.Body = p.readForGenerator(p.cur.Location)
+		if _, ok := result.Body.(ast.ForExpression).Arrow(); !ok {
+			panic("nested generators are only allowed in arrow loops")
+		}
+	} else if p.cur.Kind == lexer.TokenTypeLBrace {
 		result.Body = p.readBlockExpression()
 	} else if p.cur.Kind == lexer.TokenTypeArrow {
-		result.Body = p.readArrowExpression()
+		result.Body = p.readLoopArrowExpression()
 	} else {
 		panic("missing for loop body")
 	}
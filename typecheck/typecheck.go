@@ -0,0 +1,536 @@
+package typecheck
+
+import (
+	"fmt"
+	"ryanlang/ast"
+	"ryanlang/funcs"
+	"ryanlang/lexer"
+)
+
+// Error is a single static type error found by the checker
+type Error struct {
+	Msg string
+	Loc *lexer.Location
+}
+
+func (e Error) Error() string {
+	return e.Loc.String() + ": " + e.Msg
+}
+
+type environment struct {
+	types  map[string]*Type
+	parent *environment
+}
+
+func newEnvironment(parent *environment) *environment {
+	return &environment{
+		types:  map[string]*Type{},
+		parent: parent,
+	}
+}
+func (e *environment) get(name string) *Type {
+	for env := e; env != nil; env = env.parent {
+		if t, ok := env.types[name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+func (e *environment) set(name string, t *Type) {
+	e.types[name] = t
+}
+
+// Checker is a static pass over a module that verifies optional type annotations.
+// Code without annotations is typed as `any` where nothing can be inferred, so it always passes.
+type Checker struct {
+	env     *environment
+	returns []*Type // expected return types of the enclosing functions
+	loc     *lexer.Location
+	errors  []Error
+}
+
+func New() *Checker {
+	env := newEnvironment(nil)
+	for key := range funcs.BuiltinFunctions {
+		env.set(key, typeFunc)
+	}
+	env.set("true", typeBool)
+	env.set("false", typeBool)
+	env.set("null", typeNull)
+	return &Checker{env: env}
+}
+
+// Check runs the type checker over the module and returns all found errors
+func Check(mod ast.Module) []Error {
+	c := New()
+	c.checkModule(mod)
+	return c.errors
+}
+
+func (c *Checker) errorf(loc *lexer.Location, format string, args ...interface{}) {
+	if loc == nil {
+		loc = c.loc // not all nodes carry a location, fall back to the enclosing statement
+	}
+	c.errors = append(c.errors, Error{Msg: fmt.Sprintf(format, args...), Loc: loc})
+}
+func (c *Checker) enterScope() {
+	c.env = newEnvironment(c.env)
+}
+func (c *Checker) leaveScope() {
+	c.env = c.env.parent
+}
+func (c *Checker) expect(expr ast.Expression, t *Type, context string) *Type {
+	actual := c.check(expr)
+	if !actual.assignable(t) {
+		c.errorf(expr.Location(), "%s: expected %s, got %s", context, t.String(), actual.String())
+	}
+	return actual
+}
+
+func (c *Checker) checkModule(mod ast.Module) {
+	c.enterScope()
+	defer c.leaveScope()
+	for _, stmt := range mod.Block.Stmts {
+		c.check(stmt)
+	}
+}
+
+func (c *Checker) check(expr ast.Expression) *Type {
+	switch expr := expr.(type) {
+	case ast.Statement:
+		if loc := expr.Location(); loc != nil {
+			c.loc = loc
+		}
+		return c.check(expr.Expr)
+	case ast.NumberExpression:
+		return typeNumber
+	case ast.String:
+		return typeString
+	case ast.Identifier:
+		if t := c.env.get(expr.Name); t != nil {
+			return t
+		}
+		return typeAny // undeclared identifiers are reported by the engines
+	case ast.PlusExpression:
+		return c.checkPlus(expr)
+	case ast.MinusExpression:
+		return c.checkArithmetic(expr.Left, expr.Right, "-")
+	case ast.MultExpression:
+		return c.checkArithmetic(expr.Left, expr.Right, "*")
+	case ast.DivExpression:
+		return c.checkArithmetic(expr.Left, expr.Right, "/")
+	case ast.ModExpression:
+		return c.checkArithmetic(expr.Left, expr.Right, "%")
+	case ast.PowExpression:
+		return c.checkArithmetic(expr.Left, expr.Right, "**")
+	case ast.PrefixMinusExpression:
+		c.expect(expr.Expr, typeNumber, "operator -")
+		return typeNumber
+	case ast.GtExpression:
+		return c.checkComparison(expr.Left, expr.Right, ">")
+	case ast.LtExpression:
+		return c.checkComparison(expr.Left, expr.Right, "<")
+	case ast.GteExpression:
+		return c.checkComparison(expr.Left, expr.Right, ">=")
+	case ast.LteExpression:
+		return c.checkComparison(expr.Left, expr.Right, "<=")
+	case ast.EqTestExpression:
+		c.check(expr.Left)
+		c.check(expr.Right)
+		return typeBool
+	case ast.InExpression:
+		c.check(expr.Left)
+		switch container := c.check(expr.Right); container.kind {
+		case kindAny, kindArray, kindTuple, kindMap, kindString:
+		default:
+			c.errorf(expr.Right.Location(), "operator in is not supported on %s", container.String())
+		}
+		return typeBool
+	case ast.LogicalAndExpression:
+		c.expect(expr.Left, typeBool, "operator &&")
+		c.checkShortCircuit(expr.Left, false, expr.Right, "operator &&")
+		return typeBool
+	case ast.LogicalOrExpression:
+		c.expect(expr.Left, typeBool, "operator ||")
+		c.checkShortCircuit(expr.Left, true, expr.Right, "operator ||")
+		return typeBool
+	case ast.NegationExpression:
+		c.expect(expr.Expr, typeBool, "operator !")
+		return typeBool
+	case ast.LetExpression:
+		return c.checkLet(expr)
+	case ast.AssignExpression:
+		return c.checkAssign(expr.Identifier, c.check(expr.Value), expr.Value.Location())
+	case ast.FieldAccessExpression:
+		return c.checkFieldAccess(expr)
+	case ast.FieldAssignExpression:
+		c.check(expr.FieldAccess)
+		return c.check(expr.Value)
+	case ast.TupleExpression:
+		for _, e := range expr.Exprs {
+			c.check(e)
+		}
+		return typeTuple
+	case ast.TupleAssignExpression:
+		return c.checkTupleAssign(expr)
+	case ast.IfExpression:
+		return c.checkIf(expr)
+	case ast.BlockExpression:
+		c.enterScope()
+		defer c.leaveScope()
+		for _, stmt := range expr.Stmts {
+			c.check(stmt)
+		}
+		return typeNull
+	case ast.GroupExpression:
+		return c.check(expr.Expr)
+	case ast.ArrowExpression:
+		if expr.Key != nil {
+			c.check(expr.Key)
+		}
+		return c.check(expr.Expr)
+	case ast.FuncExpression:
+		return c.checkFunc(expr)
+	case ast.CallExpression:
+		return c.checkCall(expr)
+	case ast.ReturnExpression:
+		return c.checkReturn(expr)
+	case ast.DeferExpression:
+		c.check(expr.Expr)
+		return typeNull
+	case ast.ContinueExpression, ast.BreakExpression:
+		return typeAny
+	case ast.WhileExpression:
+		c.enterScope()
+		defer c.leaveScope()
+		c.expect(expr.Condition, typeBool, "while condition")
+		body := c.check(expr.Body)
+		if arrow, ok := expr.Body.(ast.ArrowExpression); ok {
+			return loopResult(arrow, body)
+		}
+		return typeAny
+	case ast.ForExpression:
+		return c.checkFor(expr)
+	case ast.ArrayExpression:
+		var elem *Type
+		for _, item := range expr.Items {
+			t := c.check(item)
+			if elem == nil {
+				elem = t
+			} else {
+				elem = join(elem, t)
+			}
+		}
+		if elem == nil {
+			elem = typeAny
+		}
+		return arrayOf(elem)
+	case ast.StructExpression:
+		c.enterScope()
+		defer c.leaveScope()
+		c.env.set("this", typeStruct)
+		for _, field := range expr.Fields {
+			c.check(field)
+		}
+		return typeStruct
+	case ast.MapExpression:
+		for _, field := range expr.Fields {
+			c.check(field.Key)
+			c.check(field.Value)
+		}
+		return typeMap
+	case ast.Exports:
+		for name, field := range expr.Fields {
+			if field != nil {
+				c.env.set(name, c.check(field))
+			}
+		}
+		return typeNull
+	case ast.Import:
+		c.expect(expr.Module, typeString, "import")
+		return typeModule
+	}
+
+	return typeAny
+}
+
+func (c *Checker) checkPlus(expr ast.PlusExpression) *Type {
+	left := c.check(expr.Left)
+	right := c.check(expr.Right)
+	if left.isAny() || right.isAny() {
+		if left.is(kindNumber) || left.is(kindString) {
+			return left
+		}
+		if right.is(kindNumber) || right.is(kindString) {
+			return right
+		}
+		return typeAny
+	}
+	if left.kind == right.kind && (left.is(kindNumber) || left.is(kindString) || left.is(kindArray)) {
+		return join(left, right)
+	}
+	c.errorf(expr.Location(), "incompatible types for the plus operator: %s, %s", left.String(), right.String())
+	return typeAny
+}
+func (c *Checker) checkArithmetic(left, right ast.Expression, operator string) *Type {
+	c.expect(left, typeNumber, "operator "+operator)
+	c.expect(right, typeNumber, "operator "+operator)
+	return typeNumber
+}
+func (c *Checker) checkComparison(left, right ast.Expression, operator string) *Type {
+	l := c.check(left)
+	r := c.check(right)
+	for _, t := range []*Type{l, r} {
+		if !t.isAny() && !t.is(kindNumber) && !t.is(kindString) {
+			c.errorf(left.Location(), "operator %s: cannot compare %s", operator, t.String())
+			return typeBool
+		}
+	}
+	if !l.assignable(r) {
+		c.errorf(left.Location(), "operator %s: cannot compare %s and %s", operator, l.String(), r.String())
+	}
+	return typeBool
+}
+func (c *Checker) checkLet(expr ast.LetExpression) *Type {
+	declared := make([]*Type, len(expr.Identifiers))
+	for i, id := range expr.Identifiers {
+		declared[i] = c.fromAnnotation(id.Type)
+		// declare before checking the initialization so that recursive functions can see themselves
+		c.env.set(id.Name, declared[i])
+	}
+
+	var initTypes []*Type
+	var ret *Type
+	if tuple, ok := expr.Initialization.(ast.TupleExpression); ok && len(expr.Identifiers) > 1 {
+		for _, e := range tuple.Exprs {
+			initTypes = append(initTypes, c.check(e))
+		}
+		ret = typeTuple
+	} else {
+		ret = c.check(expr.Initialization)
+		if len(expr.Identifiers) == 1 {
+			initTypes = []*Type{ret}
+		}
+	}
+
+	for i, id := range expr.Identifiers {
+		if i >= len(initTypes) {
+			break // can't tell what's inside a tuple returned by a call
+		}
+		if id.Type == nil {
+			if initTypes[i].is(kindFunc) && initTypes[i].params != nil {
+				// the signature comes from the annotations of the function itself
+				c.env.set(id.Name, initTypes[i])
+			}
+			// anything else inferred for an unannotated variable stays dynamic
+			continue
+		}
+		if !initTypes[i].assignable(declared[i]) {
+			c.errorf(id.Location(), "cannot initialize %s of type %s with a value of type %s", id.Name, declared[i].String(), initTypes[i].String())
+		}
+	}
+	return ret
+}
+func (c *Checker) checkAssign(id ast.Identifier, value *Type, loc *lexer.Location) *Type {
+	declared := c.env.get(id.Name)
+	if declared != nil && !value.assignable(declared) {
+		if loc == nil {
+			loc = id.Location()
+		}
+		c.errorf(loc, "cannot assign a value of type %s to %s of type %s", value.String(), id.Name, declared.String())
+	}
+	return value
+}
+func (c *Checker) checkTupleAssign(expr ast.TupleAssignExpression) *Type {
+	values, isTuple := expr.Value.(ast.TupleExpression)
+	if !isTuple || len(values.Exprs) != len(expr.Tuple.Exprs) {
+		c.check(expr.Value)
+		return typeNull
+	}
+	for i, e := range expr.Tuple.Exprs {
+		value := c.check(values.Exprs[i])
+		switch e := e.(type) {
+		case ast.Identifier:
+			c.checkAssign(e, value, values.Exprs[i].Location())
+		default:
+			c.check(e)
+		}
+	}
+	return typeNull
+}
+func (c *Checker) checkFieldAccess(expr ast.FieldAccessExpression) *Type {
+	left := c.check(expr.Left)
+	right := c.check(expr.Right)
+	switch left.kind {
+	case kindArray:
+		if !right.assignable(typeNumber) {
+			c.errorf(expr.Right.Location(), "array index: expected number, got %s", right.String())
+		}
+		return left.elem
+	case kindString:
+		if !right.assignable(typeNumber) {
+			c.errorf(expr.Right.Location(), "string index: expected number, got %s", right.String())
+		}
+		return typeString
+	case kindNumber, kindBool, kindNull, kindFunc:
+		c.errorf(expr.Location(), "field access operator is not supported on type %s", left.String())
+	}
+	return typeAny
+}
+func (c *Checker) checkIf(expr ast.IfExpression) *Type {
+	c.enterScope()
+	defer c.leaveScope()
+	c.expect(expr.Condition, typeBool, "if condition")
+
+	c.enterScope()
+	then := c.check(expr.Then)
+	c.leaveScope()
+	if expr.Else == nil {
+		return typeAny
+	}
+	c.enterScope()
+	els := c.check(expr.Else)
+	c.leaveScope()
+
+	return join(then, els)
+}
+func (c *Checker) checkFunc(expr ast.FuncExpression) *Type {
+	t := &Type{
+		kind:   kindFunc,
+		params: make([]*Type, len(expr.Arguments)),
+		ret:    c.fromAnnotation(expr.ReturnType),
+	}
+
+	c.enterScope()
+	defer c.leaveScope()
+	for i, arg := range expr.Arguments {
+		t.params[i] = c.fromAnnotation(arg.Type)
+		c.env.set(arg.Name, t.params[i])
+	}
+
+	c.returns = append(c.returns, t.ret)
+	c.check(expr.Body)
+	c.returns = c.returns[:len(c.returns)-1]
+
+	return t
+}
+func (c *Checker) checkReturn(expr ast.ReturnExpression) *Type {
+	t := c.check(expr.Expr)
+	if len(c.returns) > 0 {
+		expected := c.returns[len(c.returns)-1]
+		if !t.assignable(expected) {
+			c.errorf(expr.Expr.Location(), "cannot return a value of type %s from a function returning %s", t.String(), expected.String())
+		}
+	}
+	return typeAny
+}
+func (c *Checker) checkCall(expr ast.CallExpression) *Type {
+	callee := c.check(expr.Callee)
+	if !callee.isAny() && !callee.is(kindFunc) {
+		c.errorf(expr.Location(), "cannot call a value of type %s", callee.String())
+	}
+
+	if id, ok := expr.Callee.(ast.Identifier); ok && callee.params == nil {
+		if builtin, ok := funcs.BuiltinFunctions[id.Name]; ok && c.env.get(id.Name) == typeFunc && !builtin.Accepts(len(expr.Arguments)) {
+			c.errorf(expr.Location(), "built-in %s: expected %s arguments, got %d", id.Name, builtin.Arity(), len(expr.Arguments))
+		}
+	}
+
+	if callee.params == nil {
+		for _, arg := range expr.Arguments {
+			c.check(arg)
+		}
+		return typeAny
+	}
+
+	if len(callee.params) != len(expr.Arguments) {
+		c.errorf(expr.Location(), "expected %d arguments, got %d", len(callee.params), len(expr.Arguments))
+	}
+	for i, arg := range expr.Arguments {
+		if i < len(callee.params) {
+			c.expect(arg, callee.params[i], fmt.Sprintf("argument %d", i+1))
+		} else {
+			c.check(arg)
+		}
+	}
+	return callee.ret
+}
+func (c *Checker) checkFor(expr ast.ForExpression) *Type {
+	r := c.check(expr.Range)
+
+	c.enterScope()
+	defer c.leaveScope()
+	index, value := typeAny, typeAny
+	switch r.kind {
+	case kindArray:
+		index, value = typeNumber, r.elem
+	case kindString:
+		index, value = typeNumber, typeString
+	case kindAny, kindMap:
+	default:
+		c.errorf(expr.Range.Location(), "cannot iterate over type %s", r.String())
+	}
+	if expr.Index != nil {
+		c.env.set(expr.Index.Name, index)
+	}
+	c.env.set(expr.Value.Name, value)
+
+	if expr.Filter != nil {
+		c.expect(expr.Filter, typeBool, "loop filter")
+	}
+
+	body := c.check(expr.Body)
+	switch b := expr.Body.(type) {
+	case ast.ArrowExpression:
+		return loopResult(b, body)
+	case ast.ForExpression:
+		return body // nested generator, resolves to the same result as the innermost loop
+	}
+	return typeAny
+}
+
+// checkShortCircuit checks the right operand of && or ||, which is never evaluated when the left one is the constant
+// skip: its type doesn't matter then
+func (c *Checker) checkShortCircuit(left ast.Expression, skip bool, right ast.Expression, context string) {
+	if value, ok := constBool(left); ok && value == skip {
+		c.check(right)
+		return
+	}
+	c.expect(right, typeBool, context)
+}
+
+// constBool returns the value of a boolean expression made only of the true and false literals
+func constBool(expr ast.Expression) (value bool, ok bool) {
+	switch expr := expr.(type) {
+	case ast.Identifier:
+		return expr.Name == "true", expr.Name == "true" || expr.Name == "false"
+	case ast.GroupExpression:
+		return constBool(expr.Expr)
+	case ast.NegationExpression:
+		value, ok = constBool(expr.Expr)
+		return !value, ok
+	case ast.LogicalAndExpression:
+		if left, ok := constBool(expr.Left); ok && !left {
+			return false, true
+		}
+		left, leftOk := constBool(expr.Left)
+		right, rightOk := constBool(expr.Right)
+		return left && right, leftOk && rightOk
+	case ast.LogicalOrExpression:
+		if left, ok := constBool(expr.Left); ok && left {
+			return true, true
+		}
+		left, leftOk := constBool(expr.Left)
+		right, rightOk := constBool(expr.Right)
+		return left || right, leftOk && rightOk
+	}
+	return false, false
+}
+
+// loopResult returns the type of a loop with an arrow body producing values of type t
+func loopResult(arrow ast.ArrowExpression, t *Type) *Type {
+	if arrow.Key != nil {
+		return typeMap
+	}
+	return arrayOf(t)
+}
@@ -0,0 +1,116 @@
+package typecheck
+
+import (
+	"os"
+	"path/filepath"
+	"ryanlang/lexer"
+	"ryanlang/parser"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tc := []struct {
+		s    string
+		errs []string
+	}{
+		{s: "let x = 1; let y = x + 2;"},
+		{s: "let x: number = 1; let s: string = \"a\"; let b: bool = x > 0;"},
+		{s: "let f = func(a, b) => a + b; f(1, 2); f(\"a\", \"b\");"},
+		{s: "let f = func(a: number, b: [number]): number => a + len(b); f(1, [2, 3]);"},
+		{s: "let x: number = \"a\";", errs: []string{
+			"(string input):1:5: cannot initialize x of type number with a value of type string",
+		}},
+		{s: "let x: number = 1; x = \"a\";", errs: []string{
+			"(string input):1:24: cannot assign a value of type string to x of type number",
+		}},
+		{s: "let f = func(a: string): bool => a == \"\"; f(1);", errs: []string{
+			"(string input):1:45: argument 1: expected string, got number",
+		}},
+		{s: "let f = func(a: string): number => a;", errs: []string{
+			"(string input):1:33: cannot return a value of type string from a function returning number",
+		}},
+		{s: "let f = func(a: number) => a; f(1, 2);", errs: []string{
+			"(string input):1:31: expected 1 arguments, got 2",
+		}},
+		{s: "let a: [string] = for x in [1, 2] => x * 2;", errs: []string{
+			"(string input):1:5: cannot initialize a of type [string] with a value of type [number]",
+		}},
+		{s: "let a: [number] = for x in [1, 2], y in [3] if x > 1 => x * y; let m: map = for x in a => x: x;"},
+		{s: "let a = for x in [1, 2] if x => x;", errs: []string{
+			"(string input):1:28: loop filter: expected bool, got number",
+		}},
+		{s: "let ok: bool = 1 in [1, 2] && \"a\" not in \"bcd\";"},
+		{s: "let ok = 1 in 2;", errs: []string{
+			"(string input):1:15: operator in is not supported on number",
+		}},
+		{s: "let x: number = -2 ** 3 ** 2; let y = x ** \"a\";", errs: []string{
+			"(string input):1:44: operator **: expected number, got string",
+		}},
+		{s: "sort([2, 1]); sort([2, 1], func(a, b) => a - b); sort([1], null, 1);", errs: []string{
+			"(string input):1:50: built-in sort: expected 1 to 2 arguments, got 3",
+		}},
+		{s: "println(format(\"{:>3} {}\", 1, \"a\"), true); format();", errs: []string{
+			"(string input):1:44: built-in format: expected at least 1 arguments, got 0",
+		}},
+		{s: "let x: integer = 1;", errs: []string{
+			"(string input):1:8: unknown type: integer",
+		}},
+		{s: "let x = 1 + \"a\";", errs: []string{
+			"(string input):1:9: incompatible types for the plus operator: number, string",
+		}},
+		{s: "let x = 1; x = \"a\"; let ok = x && true;"},
+		{s: "let ok: bool = false && 1 / 0;"},
+		{s: "let ok: bool = true && 1;", errs: []string{
+			"(string input):1:24: operator &&: expected bool, got number",
+		}},
+		{s: "let ok: bool = true || 1 / 0; let ok2: bool = !true && 1; let ok3: bool = (false || true) || \"a\";"},
+		{s: "let b: bool = false; let ok: bool = b && 1; let ok2: bool = false || 1;", errs: []string{
+			"(string input):1:42: operator &&: expected bool, got number",
+			"(string input):1:70: operator ||: expected bool, got number",
+		}},
+		{s: "let s: string = \"ab\"; let n: number = 0; for i, c in s { n = n + i; s = c; };"},
+		{s: "let n: number = 5; for x in n {};", errs: []string{
+			"(string input):1:29: cannot iterate over type number",
+		}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.s, func(t *testing.T) {
+			p := parser.New(lexer.NewFromString(tt.s))
+			errs := Check(p.ReadModule("test"))
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("want %d errors, got %v", len(tt.errs), errs)
+			}
+			for i, err := range errs {
+				if err.Error() != tt.errs[i] {
+					t.Errorf("want=%s, got=%s", tt.errs[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+// unannotated programs must always pass
+func TestCheckFile(t *testing.T) {
+	files, err := filepath.Glob("../tests/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range files {
+		if strings.Contains(filepath.Base(fn), "_input") {
+			continue // puzzle inputs rather than programs
+		}
+		t.Run(fn, func(t *testing.T) {
+			f, err := os.Open(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			p := parser.New(lexer.New(f, fn))
+			if errs := Check(p.ReadModule(fn)); len(errs) > 0 {
+				t.Errorf("want no errors, got %v", errs)
+			}
+		})
+	}
+}
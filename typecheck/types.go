@@ -0,0 +1,167 @@
+package typecheck
+
+import (
+	"ryanlang/ast"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindAny kind = iota // unknown at compile time, compatible with everything (gradual typing)
+	kindNumber
+	kindString
+	kindBool
+	kindNull
+	kindArray
+	kindMap
+	kindStruct
+	kindFunc
+	kindTuple
+	kindModule
+)
+
+var typeNames = map[string]kind{
+	"any":     kindAny,
+	"number":  kindNumber,
+	"string":  kindString,
+	"bool":    kindBool,
+	"boolean": kindBool,
+	"null":    kindNull,
+	"map":     kindMap,
+	"struct":  kindStruct,
+	"func":    kindFunc,
+	"tuple":   kindTuple,
+	"module":  kindModule,
+}
+
+type Type struct {
+	kind kind
+	elem *Type // array items
+
+	// function signature, only known for functions declared in the checked code
+	params []*Type
+	ret    *Type
+}
+
+var (
+	typeAny    = &Type{kind: kindAny}
+	typeNumber = &Type{kind: kindNumber}
+	typeString = &Type{kind: kindString}
+	typeBool   = &Type{kind: kindBool}
+	typeNull   = &Type{kind: kindNull}
+	typeMap    = &Type{kind: kindMap}
+	typeStruct = &Type{kind: kindStruct}
+	typeFunc   = &Type{kind: kindFunc}
+	typeTuple  = &Type{kind: kindTuple}
+	typeModule = &Type{kind: kindModule}
+)
+
+func arrayOf(elem *Type) *Type {
+	return &Type{kind: kindArray, elem: elem}
+}
+
+func (t *Type) String() string {
+	switch t.kind {
+	case kindAny:
+		return "any"
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	case kindBool:
+		return "bool"
+	case kindNull:
+		return "null"
+	case kindArray:
+		return "[" + t.elem.String() + "]"
+	case kindMap:
+		return "map"
+	case kindStruct:
+		return "struct"
+	case kindFunc:
+		if t.params == nil {
+			return "func"
+		}
+		params := []string{}
+		for _, p := range t.params {
+			params = append(params, p.String())
+		}
+		return "func(" + strings.Join(params, ", ") + "): " + t.ret.String()
+	case kindTuple:
+		return "tuple"
+	case kindModule:
+		return "module"
+	default:
+		panic("unknown type kind")
+	}
+}
+
+func (t *Type) isAny() bool {
+	return t.kind == kindAny
+}
+
+// is reports whether the type is known to be of the given kind
+func (t *Type) is(k kind) bool {
+	return t.kind == k
+}
+
+// assignable reports whether a value of type t can be stored where type to is expected
+func (t *Type) assignable(to *Type) bool {
+	if t.isAny() || to.isAny() {
+		return true
+	}
+	if t.kind != to.kind {
+		return false
+	}
+	switch t.kind {
+	case kindArray:
+		return t.elem.assignable(to.elem)
+	case kindFunc:
+		if t.params == nil || to.params == nil {
+			return true
+		}
+		if len(t.params) != len(to.params) {
+			return false
+		}
+		for i := range t.params {
+			if !to.params[i].assignable(t.params[i]) {
+				return false
+			}
+		}
+		return t.ret.assignable(to.ret)
+	}
+	return true
+}
+
+// join returns the most specific type both a and b conform to
+func join(a, b *Type) *Type {
+	if a.kind != b.kind || a.isAny() {
+		return typeAny
+	}
+	if a.kind == kindArray {
+		return arrayOf(join(a.elem, b.elem))
+	}
+	if a.kind == kindFunc && !(a.assignable(b) && b.assignable(a)) {
+		return typeFunc
+	}
+	return a
+}
+
+func (c *Checker) fromAnnotation(annotation *ast.TypeAnnotation) *Type {
+	if annotation == nil {
+		return typeAny
+	}
+	if annotation.Elem != nil {
+		return arrayOf(c.fromAnnotation(annotation.Elem))
+	}
+	k, ok := typeNames[annotation.Name]
+	if !ok {
+		c.errorf(annotation.Location(), "unknown type: %s", annotation.Name)
+		return typeAny
+	}
+	if k == kindArray {
+		return arrayOf(typeAny)
+	}
+	return &Type{kind: k}
+}
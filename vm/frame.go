@@ -11,4 +11,9 @@ type Frame struct {
 	cpe    int
 	bsp    int
 	labels map[instruction.LabelKind]int
+	defers []*object.Closure // deferred calls, executed in reverse order when leaving the frame
+
+	// barrier is set for frames entered from Go code (see callClosure),
+	// returns cannot unwind the stack past such frames
+	barrier bool
 }
@@ -3,6 +3,7 @@ package vm
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"ryanlang/compiler"
 	"ryanlang/compiler/instruction"
@@ -34,6 +35,12 @@ type VM struct {
 	state       state
 	wd          *webDebugger
 	bp          *breakpoints
+	noDebugger  bool
+	stdout      io.Writer
+	stderr      io.Writer
+	clock       funcs.Clock
+	policy      funcs.Policy
+	codeIDs     map[*object.Code]int // object ids of the code objects, used to find their debug data
 }
 
 func New(compiledModule *compiler.Module) *VM {
@@ -45,6 +52,9 @@ func New(compiledModule *compiler.Module) *VM {
 		sourceFiles: map[string]string{},
 		state:       statePaused,
 		bp:          &breakpoints{},
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+		clock:       funcs.SystemClock,
 	}
 	entrypoint, ok := compiledModule.Objects.Get(compiledModule.EntryPoint)
 	if !ok {
@@ -76,19 +86,24 @@ func (v *VM) enterFrame(cl *object.Closure) {
 	if v.frames[v.fp].labels != nil && len(v.frames[v.fp].labels) != 0 {
 		v.frames[v.fp].labels = nil
 	}
+	v.frames[v.fp].defers = nil
+	v.frames[v.fp].barrier = false
 	v.frame = v.frames[v.fp]
 
 	for i := 0; i < cl.Code.Locals-cl.Code.Arguments; i++ { // reserve space on the stack for local vars
 		v.pushNull()
 	}
 }
-func (v *VM) leaveFrame() *Frame {
+func (v *VM) leaveFrame() (*Frame, error) {
 	// todo: check if there's no frames
 	// todo: check if there's more than 1 value on the stack, it might be a leak
 	if v.fp == -1 {
 		panic("frame stack empty, cannot leave frame")
 	}
 	f := v.frame
+	if err := v.runDeferred(f); err != nil {
+		return f, err
+	}
 	v.fp--
 
 	if v.fp >= 0 {
@@ -100,7 +115,192 @@ func (v *VM) leaveFrame() *Frame {
 	v.sp = f.bsp // reset stack to where it was before calling the frame
 
 	v.bp.trigger(breakpointLeaveFrame)
-	return f
+	return f, nil
+}
+
+// runDeferred executes the calls deferred in the given frame, the most recently deferred first
+func (v *VM) runDeferred(f *Frame) error {
+	for len(f.defers) > 0 {
+		cl := f.defers[len(f.defers)-1]
+		f.defers = f.defers[:len(f.defers)-1]
+		if _, err := v.callClosure(cl); err != nil {
+			return fmt.Errorf("deferred call: %w", err)
+		}
+	}
+	return nil
+}
+
+// runAllDeferred executes pending deferred calls of all frames, starting from the topmost one.
+// The frames themselves are left intact, so that the state can still be inspected in the debugger.
+func (v *VM) runAllDeferred() error {
+	var firstErr error
+	for i := v.fp; i >= 0; i-- {
+		for {
+			err := v.runDeferred(v.frames[i])
+			if err == nil {
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// callBuiltin calls the built-in function with the given arguments
+func (v *VM) callBuiltin(name string, args []object.Object) (object.Object, error) {
+	builtin, ok := funcs.LookupBuiltin(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown builting function name: %s", name)
+	}
+	if !builtin.Accepts(len(args)) {
+		return nil, fmt.Errorf("built-in %s: expected %s arguments, got %d", name, builtin.Arity(), len(args))
+	}
+	argsmap := map[string]object.Object{}
+	for i, arg := range builtin.Pack(args) {
+		argsmap[builtin.Arguments[i]] = arg
+	}
+	switch ret := builtin.Invoke(v, argsmap).(type) {
+	case *object.ReturnObject:
+		return ret.Obj, nil
+	case *object.Error:
+		return nil, fmt.Errorf("built-in %s: %s", name, ret.String())
+	default:
+		return &object.StaticNull, nil
+	}
+}
+
+// callClosure calls the closure with the given arguments and runs it until it returns.
+// If the call fails, the stack is restored to the state before the call.
+func (v *VM) callClosure(cl *object.Closure, args ...object.Object) (object.Object, error) {
+	if cl.BuiltinFunctionName != "" {
+		return v.callBuiltin(cl.BuiltinFunctionName, args)
+	}
+	if cl.Memo != nil {
+		return v.callMemoized(cl, args)
+	}
+
+	if cl.Code.Arguments != len(args) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", cl.Code.Arguments, len(args))
+	}
+	sp, fp := v.sp, v.fp
+	for i := range args {
+		v.push(&args[i])
+	}
+	v.enterFrame(cl)
+	v.frame.barrier = true
+	for v.fp > fp {
+		if _, err := v.step(); err != nil {
+			for i := v.fp; i > fp; i-- {
+				if deferErr := v.runDeferred(v.frames[i]); deferErr != nil {
+					err = fmt.Errorf("%w (%s)", err, deferErr.Error())
+				}
+			}
+			v.fp, v.frame, v.sp = fp, nil, sp
+			if fp >= 0 {
+				v.frame = v.frames[fp]
+			}
+			return nil, err
+		}
+	}
+	// the result is taken off the stack directly rather than with pop, as there is no frame to pop from when the
+	// program has already finished, e.g. when the host calls a closure exported by the program after Run
+	ret := *v.stack[v.sp]
+	v.sp = sp
+	return ret, nil
+}
+
+// callMemoized returns the cached result of the closure, or calls it and caches the result unless it fails
+func (v *VM) callMemoized(cl *object.Closure, args []object.Object) (object.Object, error) {
+	key, err := cl.Memo.Key(args)
+	if err != nil {
+		return nil, err
+	}
+	if ret, ok := cl.Memo.Get(key); ok {
+		return ret, nil
+	}
+	plain := *cl
+	plain.Memo = nil
+	ret, err := v.callClosure(&plain, args...)
+	if err == nil {
+		cl.Memo.Put(key, ret)
+	}
+	return ret, err
+}
+
+// Call implements funcs.Context, it lets built-in functions call closures
+func (v *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	cl, ok := fn.(*object.Closure)
+	if !ok {
+		return &object.Error{Msg: "function expected, got: " + fn.Type().String()}
+	}
+	ret, err := v.callClosure(cl, args...)
+	if err != nil {
+		return &object.Error{Msg: err.Error()}
+	}
+	return ret
+}
+
+// SetOutput redirects the output of the program
+func (v *VM) SetOutput(stdout io.Writer, stderr io.Writer) {
+	v.stdout = stdout
+	v.stderr = stderr
+}
+
+// SetClock replaces the clock of the program, e.g. with a funcs.FakeClock
+func (v *VM) SetClock(clock funcs.Clock) {
+	v.clock = clock
+}
+
+// Clock implements funcs.Context
+func (v *VM) Clock() funcs.Clock {
+	return v.clock
+}
+
+// SetPolicy restricts what the program can do to the host
+func (v *VM) SetPolicy(policy funcs.Policy) {
+	v.policy = policy
+}
+
+// Policy implements funcs.Context
+func (v *VM) Policy() funcs.Policy {
+	return v.policy
+}
+
+// Stdout implements funcs.Context
+func (v *VM) Stdout() io.Writer {
+	return v.stdout
+}
+
+// Stderr implements funcs.Context
+func (v *VM) Stderr() io.Writer {
+	return v.stderr
+}
+
+// Location implements funcs.Context, it's the source location of the instruction being executed, nil if unknown
+func (v *VM) Location() *lexer.Location {
+	if v.frame == nil {
+		return nil
+	}
+	id, ok := v.codeIDs[v.frame.cl.Code]
+	if !ok {
+		// imported modules add code objects while running
+		v.codeIDs = map[*object.Code]int{}
+		for i := uint16(0); i < v.objects.Len(); i++ {
+			if obj, _ := v.objects.Get(i); obj.Type() == object.CODE {
+				v.codeIDs[obj.(*object.Code)] = int(i)
+			}
+		}
+		if id, ok = v.codeIDs[v.frame.cl.Code]; !ok {
+			return nil
+		}
+	}
+	entry := v.debugData[id].SearchSource(v.frame.cpe)
+	if entry == nil {
+		return nil
+	}
+	return entry.Location()
 }
 func (v *VM) returnFromFrame(scope object.CodeReturnScope) error {
 	if v.frame == nil {
@@ -116,11 +316,14 @@ func (v *VM) returnFromFrame(scope object.CodeReturnScope) error {
 	}
 
 	for {
-		f := v.leaveFrame()
+		f, err := v.leaveFrame()
+		if err != nil {
+			return err
+		}
 		if f.cl.Code.ReturnScope == scope {
 			break
 		}
-		if f.cl.Code.ReturnScope < scope {
+		if f.cl.Code.ReturnScope < scope || f.barrier {
 			return fmt.Errorf("cannot return because there was no appropriate scope to return from")
 		}
 	}
@@ -182,101 +385,105 @@ func (v *VM) top() *object.Object {
 func (v *VM) setState(state state) {
 	v.state = state
 }
+
+var binaryOps = map[instruction.Op]func(left object.Object, right object.Object) object.Object{
+	instruction.OpAdd:         funcs.Plus,
+	instruction.OpSub:         funcs.Minus,
+	instruction.OpMult:        funcs.Mult,
+	instruction.OpDiv:         funcs.Div,
+	instruction.OpMod:         funcs.Mod,
+	instruction.OpPow:         funcs.Pow,
+	instruction.OpGt:          funcs.Gt,
+	instruction.OpGte:         funcs.Gte,
+	instruction.OpLt:          funcs.Lt,
+	instruction.OpLte:         funcs.Lte,
+	instruction.OpEqTest:      funcs.EqTest,
+	instruction.OpIn:          funcs.In,
+	instruction.OpFieldAccess: funcs.FieldAccess,
+	instruction.OpLogicalOr:   funcs.LogicalOr,
+}
+
 func (v *VM) next() (bool, error) {
 	v.setState(stateRunning)
 	defer v.setState(statePaused)
 
-	args := make([]interface{}, 2)
-	var c []byte
-	var op instruction.Op
-	var n int
-	binaryOps := map[instruction.Op]func(left object.Object, right object.Object) object.Object{
-		instruction.OpAdd:         funcs.Plus,
-		instruction.OpSub:         funcs.Minus,
-		instruction.OpMult:        funcs.Mult,
-		instruction.OpDiv:         funcs.Div,
-		instruction.OpMod:         funcs.Mod,
-		instruction.OpGt:          funcs.Gt,
-		instruction.OpGte:         funcs.Gte,
-		instruction.OpLt:          funcs.Lt,
-		instruction.OpLte:         funcs.Lte,
-		instruction.OpEqTest:      funcs.EqTest,
-		instruction.OpFieldAccess: funcs.FieldAccess,
-		instruction.OpLogicalOr:   funcs.LogicalOr,
-	}
 	for {
 		if v.frame == nil {
 			// todo: no frames left, no code left, nothing to do?
 			return false, nil
 		}
-		c = v.frame.cl.Code.Code
-		if v.frame.cp >= len(c) {
-			// todo: end of code reached, possibly return missing?
-			//fmt.Printf("leaving frame, top: %s\n", (*v.top()).String())
-			t := v.top()
-			v.leaveFrame()
-			v.push(t) // frame will resolve to whatever what on top of the stack when we were leaving
-			//v.pushNull()
-			//return true, nil
+		executed, err := v.step()
+		if err != nil {
+			if deferErr := v.runAllDeferred(); deferErr != nil {
+				err = fmt.Errorf("%w (%s)", err, deferErr.Error())
+			}
+			return false, err
+		}
+		if !executed {
 			continue
 		}
-		//inst, n, err := instruction.Read(c[v.frame().cp:])
 
-		op, n = instruction.ReadFast(c, v.frame.cp, args)
+		v.bp.trigger(breakpointStep)
+		if v.bp.halt() {
+			break
+		}
+	}
+	return true, nil
+}
 
-		//if err != nil {
-		//	panic(fmt.Errorf("reading instruction: %w", err))
-		//}
-		v.frame.cpe = v.frame.cp
-		v.frame.cp += n
-
-		switch op {
-		default:
-			handler, ok := binaryOps[op]
-			if ok {
-				r := handler(*v.pop(), *v.pop())
-				if object.IsError(r) {
-					return false, fmt.Errorf("operator %s: %s", op.String(), r.(*object.Error).String())
-				}
-				v.push(&r)
-			} else {
-				panic("dont know how to run op: " + op.String())
-			}
-		case instruction.OpPushConstant:
-			obj, ok := v.objects.GetRef(args[0].(uint16))
-			if !ok {
-				return false, fmt.Errorf("constant index out of range")
-			}
-			v.push(obj)
-		case instruction.OpJnt:
-			addrType := args[0].(uint8)
-			addr := args[1].(uint16)
-			top, err := v.expectPop(object.BOOLEAN)
-			if err != nil {
-				return false, fmt.Errorf("operator %s: %w", op.String(), err)
-			}
-			if (*top).(*object.Boolean).Value == false {
-				if addrType == compiler.RelativeAddress {
-					v.frame.cp += int(addr)
-				} else if addrType == compiler.AbsoluteAddress {
-					v.frame.cp = int(addr)
-				} else if addrType == compiler.RelativeAddressBackwards {
-					v.frame.cp -= int(addr)
-				} else if addrType == compiler.LabelAddress {
-					dest, ok := v.frame.labels[instruction.LabelKind(addr)]
-					if !ok {
-						return false, fmt.Errorf("label is not set")
-					}
-					v.frame.cp = dest
-				} else {
-					panic("unexpected address type")
-				}
-			}
-		case instruction.OpJmp:
-			addrType := args[0].(uint8)
-			addr := args[1].(uint16)
-			// todo: move read address into separate func
-			// todo: move handle jump to an address into separate func
+// step executes a single instruction of the current frame.
+// It returns false if there was no instruction to execute, but the frame has been left instead.
+func (v *VM) step() (bool, error) {
+	var args [2]interface{}
+	c := v.frame.cl.Code.Code
+	if v.frame.cp >= len(c) {
+		// todo: end of code reached, possibly return missing?
+		//fmt.Printf("leaving frame, top: %s\n", (*v.top()).String())
+		t := v.top()
+		if _, err := v.leaveFrame(); err != nil {
+			return false, err
+		}
+		v.push(t) // frame will resolve to whatever what on top of the stack when we were leaving
+		//v.pushNull()
+		//return true, nil
+		return false, nil
+	}
+	//inst, n, err := instruction.Read(c[v.frame().cp:])
+
+	op, n := instruction.ReadFast(c, v.frame.cp, args[:])
+
+	//if err != nil {
+	//	panic(fmt.Errorf("reading instruction: %w", err))
+	//}
+	v.frame.cpe = v.frame.cp
+	v.frame.cp += n
+
+	switch op {
+	default:
+		handler, ok := binaryOps[op]
+		if ok {
+			r := handler(*v.pop(), *v.pop())
+			if object.IsError(r) {
+				return false, fmt.Errorf("operator %s: %s", op.String(), r.(*object.Error).String())
+			}
+			v.push(&r)
+		} else {
+			panic("dont know how to run op: " + op.String())
+		}
+	case instruction.OpPushConstant:
+		obj, ok := v.objects.GetRef(args[0].(uint16))
+		if !ok {
+			return false, fmt.Errorf("constant index out of range")
+		}
+		v.push(obj)
+	case instruction.OpJnt:
+		addrType := args[0].(uint8)
+		addr := args[1].(uint16)
+		top, err := v.expectPop(object.BOOLEAN)
+		if err != nil {
+			return false, fmt.Errorf("operator %s: %w", op.String(), err)
+		}
+		if (*top).(*object.Boolean).Value == false {
 			if addrType == compiler.RelativeAddress {
 				v.frame.cp += int(addr)
 			} else if addrType == compiler.AbsoluteAddress {
@@ -292,224 +499,256 @@ func (v *VM) next() (bool, error) {
 			} else {
 				panic("unexpected address type")
 			}
-		case instruction.OpAnnotation:
-			// ignore
-		case instruction.OpCall:
-			obj, err := v.expectPop(object.CLOSURE)
-			if err != nil {
-				return false, fmt.Errorf("call: %w", err)
-			}
-			callee := (*obj).(*object.Closure)
-			if callee.BuiltinFunctionName != "" {
-				builtin, ok := funcs.BuiltinFunctions[callee.BuiltinFunctionName]
-				if !ok {
-					return false, fmt.Errorf("unknown builting function name: %s", callee.BuiltinFunctionName)
-				}
-				if len(builtin.Arguments) != int(args[0].(uint8)) {
-					return false, fmt.Errorf("built-in %s: expected %d arguments, got %d", callee.BuiltinFunctionName, len(builtin.Arguments), args[0].(uint8))
-				}
-				argsmap := map[string]object.Object{}
-				for i := len(builtin.Arguments) - 1; i >= 0; i-- {
-					argsmap[builtin.Arguments[i]] = *v.pop()
-				}
-
-				var ret object.Object
-				ret = builtin.Body(argsmap)
-				switch ret := ret.(type) {
-				case *object.ReturnObject:
-					v.push(&ret.Obj)
-				case *object.Error:
-					return false, fmt.Errorf("built-in %s: %s", callee.BuiltinFunctionName, ret.String())
-				}
-				if callee.BuiltinFunctionName == "debugger" {
-					v.bp.trigger(breakpointDebuggerCall)
-				}
-			} else {
-				if callee.Code.Arguments != int(args[0].(uint8)) {
-					return false, fmt.Errorf("expected %d arguments, got %d", callee.Code.Arguments, args[0].(uint8))
-				}
-				v.enterFrame(callee)
-				// todo: push onto the stack whatever the called function returned
-			}
-		case instruction.OpArray:
-			itemsc := int(args[0].(uint16))
-			array := &object.Array{
-				make([]object.Object, itemsc),
-			}
-			for i := 0; i < itemsc; i++ {
-				array.Items[i] = *v.pop()
-			}
-			var obj object.Object = array
-			v.push(&obj)
-		case instruction.OpStruct:
-			itemsc := int(args[0].(uint8))
-			str := &object.Struct{
-				Fields: make(map[string]object.Object, itemsc),
-			}
-			for i := 0; i < itemsc; i++ {
-				k, err := v.expectPop(object.STRING)
-				if err != nil {
-					return false, err
-				}
-				v := v.pop()
-				str.Fields[(*k).(*object.String).Value] = *v
-			}
-			var obj object.Object = str
-			v.push(&obj)
-		case instruction.OpMap:
-			itemsc := int(args[0].(uint8))
-			m := &object.Map{
-				Fields: make(map[string]object.MapItem, itemsc),
-			}
-			for i := 0; i < itemsc; i++ {
-				k := v.pop()
-				if !object.IsHashable(*k) {
-					return false, fmt.Errorf("hashable map key expected, got: %s", (*k).Type().String())
-				}
-				m.Fields[(*k).(object.Hashable).Hash()] = object.MapItem{
-					Key:   *k,
-					Value: *v.pop(),
-				}
-			}
-			var obj object.Object = m
-			v.push(&obj)
-		case instruction.OpTuple:
-			itemsc := int(args[0].(uint8))
-			t := &object.Tuple{
-				Values: make([]object.Object, itemsc),
-			}
-			for i := 0; i < itemsc; i++ {
-				t.Values[i] = *v.pop()
+		}
+	case instruction.OpJmp:
+		addrType := args[0].(uint8)
+		addr := args[1].(uint16)
+		// todo: move read address into separate func
+		// todo: move handle jump to an address into separate func
+		if addrType == compiler.RelativeAddress {
+			v.frame.cp += int(addr)
+		} else if addrType == compiler.AbsoluteAddress {
+			v.frame.cp = int(addr)
+		} else if addrType == compiler.RelativeAddressBackwards {
+			v.frame.cp -= int(addr)
+		} else if addrType == compiler.LabelAddress {
+			dest, ok := v.frame.labels[instruction.LabelKind(addr)]
+			if !ok {
+				return false, fmt.Errorf("label is not set")
 			}
-			var obj object.Object = t
-			v.push(&obj)
-		case instruction.OpUntuple:
-			itemsc := int(args[0].(uint8))
-			t, err := v.expectPop(object.TUPLE)
+			v.frame.cp = dest
+		} else {
+			panic("unexpected address type")
+		}
+	case instruction.OpAnnotation:
+		// ignore
+	case instruction.OpCall:
+		obj, err := v.expectPop(object.CLOSURE)
+		if err != nil {
+			return false, fmt.Errorf("call: %w", err)
+		}
+		callee := (*obj).(*object.Closure)
+		if callee.BuiltinFunctionName != "" || callee.Memo != nil {
+			callArgs := make([]object.Object, int(args[0].(uint8)))
+			for i := len(callArgs) - 1; i >= 0; i-- {
+				callArgs[i] = *v.pop()
+			}
+			// memoized closures are run to completion right away, so that their results can be cached
+			ret, err := v.callClosure(callee, callArgs...)
 			if err != nil {
-				return false, fmt.Errorf("untuple: %w", err)
+				return false, err
 			}
-			if len((*t).(*object.Tuple).Values) != itemsc {
-				return false, fmt.Errorf("untuple: %d items expected, got %d", itemsc, len((*t).(*object.Tuple).Values))
-			}
-			for i := len((*t).(*object.Tuple).Values) - 1; i >= 0; i-- {
-				value := (*t).(*object.Tuple).Values[i]
-				v.push(&value)
+			v.push(&ret)
+			if callee.BuiltinFunctionName == "debugger" {
+				v.bp.trigger(breakpointDebuggerCall)
 			}
-		case instruction.OpStoreLocal:
-			*v.stack[v.frame.bsp+1+int(args[0].(uint16))] = *v.top() // don't pop because this op should resolve to the assigned value
-		case instruction.OpStoreForeign:
-			*v.frame.cl.Foreigns[int(args[0].(uint16))] = *v.top() // don't pop because this op should resolve to the assigned value
-		case instruction.OpPushLocalRef:
-			// when passed like this, whoever uses this variable, they will have full control over it,
-			// it will be able to rewrite its value
-			// essentially it's like if this variable was in a closure:
-			// let f = func(x) { x++; }; let a = 1; f(a); // <-- a will now be 2.
-			if int(args[0].(uint16)) > v.frame.cl.Code.Locals {
-				return false, fmt.Errorf("trying to get local %d in a closure with %d locals", int(args[0].(uint16)), v.frame.cl.Code.Locals)
+		} else {
+			if callee.Code.Arguments != int(args[0].(uint8)) {
+				return false, fmt.Errorf("expected %d arguments, got %d", callee.Code.Arguments, args[0].(uint8))
 			}
-			v.push(v.stack[v.frame.bsp+1+int(args[0].(uint16))])
-
-			// if needed to pass a copy, use this instead (create a new pointer to the same value):
-			//val := *v.stack[v.frame.bsp+1+int(args[0].(uint16))]
-			//v.push(&val)
-		case instruction.OpCopy:
-			// new pointer now points to the same value (see comments above)
-			val := *v.top()
-			v.stack[v.sp] = &val
-		case instruction.OpDup:
-			v.push(v.top())
-		case instruction.OpPushForeign:
-			v.push(v.frame.cl.Foreigns[int(args[0].(uint16))])
-		case instruction.OpPop:
-			v.pop()
-		case instruction.OpReturn:
-			scope := object.CodeReturnScope(args[0].(uint8))
-			ret := v.pop()
-			err := v.returnFromFrame(scope)
-			if err != nil {
-				return false, fmt.Errorf("return: %w", err)
-			}
-			v.push(ret)
-		case instruction.OpClosure:
-			obj, err := v.expectPop(object.CODE)
+			v.enterFrame(callee)
+			// todo: push onto the stack whatever the called function returned
+		}
+	case instruction.OpArray:
+		itemsc := int(args[0].(uint16))
+		array := &object.Array{
+			Items: make([]object.Object, itemsc),
+		}
+		for i := 0; i < itemsc; i++ {
+			array.Items[i] = *v.pop()
+		}
+		var obj object.Object = array
+		v.push(&obj)
+	case instruction.OpStruct:
+		itemsc := int(args[0].(uint8))
+		str := &object.Struct{
+			Fields: make(map[string]object.Object, itemsc),
+		}
+		for i := 0; i < itemsc; i++ {
+			k, err := v.expectPop(object.STRING)
 			if err != nil {
-				return false, fmt.Errorf("closure: %w", err)
-			}
-			objTyped := (*obj).(*object.Code)
-			cl := &object.Closure{
-				Code:     objTyped,
-				Foreigns: make([]*object.Object, objTyped.Foreigns),
+				return false, err
 			}
-			for i := 0; i < objTyped.Foreigns; i++ {
-				cl.Foreigns[i] = v.pop()
-			}
-			var cli object.Object = cl
-			v.push(&cli)
-		case instruction.OpFieldAssign:
-			left := *v.pop()
-			right := *v.pop()
-			value := *v.pop()
-			ret := funcs.FieldAssign(left, right, value)
-			if object.IsError(ret) {
-				return false, fmt.Errorf("field assign: %s", ret.String())
+			v := v.pop()
+			str.Fields[(*k).(*object.String).Value] = *v
+		}
+		var obj object.Object = str
+		v.push(&obj)
+	case instruction.OpMap:
+		itemsc := int(args[0].(uint8))
+		m := &object.Map{
+			Fields: make(map[string]object.MapItem, itemsc),
+		}
+		for i := 0; i < itemsc; i++ {
+			k := v.pop()
+			if !object.IsHashable(*k) {
+				return false, fmt.Errorf("hashable map key expected, got: %s", (*k).Type().String())
 			}
-			v.push(&ret)
-		case instruction.OpImport:
-			modulePath, err := v.expectPop(object.STRING)
-			if err != nil {
-				return false, fmt.Errorf("import: %w", err)
+			m.Fields[(*k).(object.Hashable).Hash()] = object.MapItem{
+				Key:   *k,
+				Value: *v.pop(),
 			}
+		}
+		var obj object.Object = m
+		v.push(&obj)
+	case instruction.OpTuple:
+		itemsc := int(args[0].(uint8))
+		t := &object.Tuple{
+			Values: make([]object.Object, itemsc),
+		}
+		for i := 0; i < itemsc; i++ {
+			t.Values[i] = *v.pop()
+		}
+		var obj object.Object = t
+		v.push(&obj)
+	case instruction.OpUntuple:
+		itemsc := int(args[0].(uint8))
+		t, err := v.expectPop(object.TUPLE)
+		if err != nil {
+			return false, fmt.Errorf("untuple: %w", err)
+		}
+		if len((*t).(*object.Tuple).Values) != itemsc {
+			return false, fmt.Errorf("untuple: %d items expected, got %d", itemsc, len((*t).(*object.Tuple).Values))
+		}
+		for i := len((*t).(*object.Tuple).Values) - 1; i >= 0; i-- {
+			value := (*t).(*object.Tuple).Values[i]
+			v.push(&value)
+		}
+	case instruction.OpStoreLocal:
+		*v.stack[v.frame.bsp+1+int(args[0].(uint16))] = *v.top() // don't pop because this op should resolve to the assigned value
+	case instruction.OpStoreForeign:
+		*v.frame.cl.Foreigns[int(args[0].(uint16))] = *v.top() // don't pop because this op should resolve to the assigned value
+	case instruction.OpPushLocalRef:
+		// when passed like this, whoever uses this variable, they will have full control over it,
+		// it will be able to rewrite its value
+		// essentially it's like if this variable was in a closure:
+		// let f = func(x) { x++; }; let a = 1; f(a); // <-- a will now be 2.
+		if int(args[0].(uint16)) > v.frame.cl.Code.Locals {
+			return false, fmt.Errorf("trying to get local %d in a closure with %d locals", int(args[0].(uint16)), v.frame.cl.Code.Locals)
+		}
+		v.push(v.stack[v.frame.bsp+1+int(args[0].(uint16))])
+
+		// if needed to pass a copy, use this instead (create a new pointer to the same value):
+		//val := *v.stack[v.frame.bsp+1+int(args[0].(uint16))]
+		//v.push(&val)
+	case instruction.OpCopy:
+		// new pointer now points to the same value (see comments above)
+		val := *v.top()
+		v.stack[v.sp] = &val
+	case instruction.OpDup:
+		v.push(v.top())
+	case instruction.OpPushForeign:
+		v.push(v.frame.cl.Foreigns[int(args[0].(uint16))])
+	case instruction.OpPop:
+		v.pop()
+	case instruction.OpReturn:
+		scope := object.CodeReturnScope(args[0].(uint8))
+		// copy the returned value, so that deferred calls changing the variable don't affect it
+		val := *v.pop()
+		ret := &val
+		err := v.returnFromFrame(scope)
+		if err != nil {
+			return false, fmt.Errorf("return: %w", err)
+		}
+		v.push(ret)
+	case instruction.OpClosure:
+		obj, err := v.expectPop(object.CODE)
+		if err != nil {
+			return false, fmt.Errorf("closure: %w", err)
+		}
+		objTyped := (*obj).(*object.Code)
+		cl := &object.Closure{
+			Code:     objTyped,
+			Foreigns: make([]*object.Object, objTyped.Foreigns),
+		}
+		for i := 0; i < objTyped.Foreigns; i++ {
+			cl.Foreigns[i] = v.pop()
+		}
+		var cli object.Object = cl
+		v.push(&cli)
+	case instruction.OpFieldAssign:
+		left := *v.pop()
+		right := *v.pop()
+		value := *v.pop()
+		ret := funcs.FieldAssign(left, right, value)
+		if object.IsError(ret) {
+			return false, fmt.Errorf("field assign: %s", ret.String())
+		}
+		v.push(&ret)
+	case instruction.OpImport:
+		modulePath, err := v.expectPop(object.STRING)
+		if err != nil {
+			return false, fmt.Errorf("import: %w", err)
+		}
 
-			fn := (*modulePath).(*object.String).Value
-			f, err := os.Open(fn)
-			if err != nil {
-				return false, fmt.Errorf("import: cannot open file: %w", err)
+		fn := (*modulePath).(*object.String).Value
+		if names, ok := funcs.NativeModuleFunctions(fn); ok {
+			module := &object.Struct{Fields: make(map[string]object.Object, len(names)), Frozen: true}
+			for _, name := range names {
+				module.Fields[name] = &object.Closure{BuiltinFunctionName: fn + "." + name}
 			}
-			l := lexer.New(f, fn)
-			p := parser.New(l)
+			var obj object.Object = module
+			v.push(&obj)
+			break
+		}
 
-			mod := p.ReadModule(fn)
-			err = f.Close()
-			if err != nil {
-				return false, fmt.Errorf("import: cannot close file: %w", err)
-			}
+		f, err := os.Open(fn)
+		if err != nil {
+			return false, fmt.Errorf("import: cannot open file: %w", err)
+		}
+		l := lexer.New(f, fn)
+		p := parser.New(l)
 
-			compiledModule, err := compiler.NewCompilerWithStorage(v.objects).CompileModule(mod)
-			if err != nil {
-				return false, fmt.Errorf("import %s: module compilation error: %w", fn, err)
-			}
+		mod := p.ReadModule(fn)
+		err = f.Close()
+		if err != nil {
+			return false, fmt.Errorf("import: cannot close file: %w", err)
+		}
 
-			for id, sm := range compiledModule.DebugData {
-				v.debugData[id] = sm
-			}
-			if fnBytes, err := os.ReadFile(fn); err == nil {
-				v.AddSourceFile(fn, string(fnBytes))
-			} else {
-				return false, fmt.Errorf("import: cannot read file: %w", err)
-			}
+		compiledModule, err := compiler.NewCompilerWithStorage(v.objects).CompileModule(mod)
+		if err != nil {
+			return false, fmt.Errorf("import %s: module compilation error: %w", fn, err)
+		}
 
-			module, ok := v.objects.Get(compiledModule.EntryPoint)
-			if !ok {
-				return false, fmt.Errorf("import: cannot find module object")
-			}
-			v.push(&module)
-		case instruction.OpLabel:
-			kind := instruction.LabelKind(args[0].(uint8))
-			if v.frame.labels == nil {
-				v.frame.labels = map[instruction.LabelKind]int{}
-			}
-			v.frame.labels[kind] = v.frame.cp
+		for id, sm := range compiledModule.DebugData {
+			v.debugData[id] = sm
 		}
-		if v.frame != nil {
-			v.frame.cpe = v.frame.cp
-			v.bp.at(v.frame.cl.Code, v.frame.cp)
+		if fnBytes, err := os.ReadFile(fn); err == nil {
+			v.AddSourceFile(fn, string(fnBytes))
+		} else {
+			return false, fmt.Errorf("import: cannot read file: %w", err)
 		}
 
-		v.bp.trigger(breakpointStep)
-		if v.bp.halt() {
-			break
+		module, ok := v.objects.Get(compiledModule.EntryPoint)
+		if !ok {
+			return false, fmt.Errorf("import: cannot find module object")
+		}
+		// run the module code, it resolves to a struct with exported fields
+		v.enterFrame(&object.Closure{Code: module.(*object.Code)})
+	case instruction.OpLabel:
+		kind := instruction.LabelKind(args[0].(uint8))
+		if v.frame.labels == nil {
+			v.frame.labels = map[instruction.LabelKind]int{}
+		}
+		v.frame.labels[kind] = v.frame.cp
+	case instruction.OpDefer:
+		obj, err := v.expectPop(object.CLOSURE)
+		if err != nil {
+			return false, fmt.Errorf("defer: %w", err)
+		}
+		// loop bodies are compiled to separate closures, so attach the call to the enclosing function's frame
+		fp := v.fp
+		for fp >= 0 && v.frames[fp].cl.Code.ReturnScope != object.CodeReturnScopeFunc {
+			fp--
 		}
+		if fp < 0 {
+			return false, fmt.Errorf("defer: not inside a function")
+		}
+		v.frames[fp].defers = append(v.frames[fp].defers, (*obj).(*object.Closure))
+	}
+	if v.frame != nil {
+		v.frame.cpe = v.frame.cp
+		v.bp.at(v.frame.cl.Code, v.frame.cp)
 	}
 	return true, nil
 }
@@ -520,7 +759,25 @@ func (v *VM) EnableWebDebugger() {
 	v.wd = newWebDebugger(v)
 	go v.wd.start()
 }
+
+// DisableDebugger makes Run return runtime errors as *object.Error instead of halting in the debugger,
+// debugger() calls are ignored
+func (v *VM) DisableDebugger() {
+	v.noDebugger = true
+}
 func (v *VM) Run() object.Object {
+	if v.noDebugger {
+		for {
+			more, err := v.next()
+			if err != nil {
+				return &object.Error{Msg: err.Error(), Loc: v.Location()}
+			}
+			if !more {
+				return *v.top()
+			}
+		}
+	}
+
 	v.bp.set(breakpointDebuggerCall)
 	v.bp.set(breakpointRuntimeError)
 	for {